@@ -6,12 +6,24 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/PhilippElizarov/go_final_project/internal/clock"
 	"github.com/PhilippElizarov/go_final_project/internal/database"
-	"github.com/PhilippElizarov/go_final_project/internal/model"
+	"github.com/PhilippElizarov/go_final_project/internal/holiday"
 	"github.com/PhilippElizarov/go_final_project/internal/routes"
 	"github.com/joho/godotenv"
 	_ "github.com/mattn/go-sqlite3"
+	_ "time/tzdata"
+)
+
+// TaskStore поддерживает все возможности сервера: без них маршруты
+// отключились бы незаметно.
+var (
+	_ routes.CompletionRepository = database.TaskStore{}
+	_ routes.ExceptionRepository  = database.TaskStore{}
+	_ routes.FeedTokenRepository  = database.TaskStore{}
+	_ routes.BackupRepository     = database.TaskStore{}
 )
 
 func main() {
@@ -19,41 +31,74 @@ func main() {
 		log.Fatal(err.Error())
 	}
 
-	appPath, err := os.Getwd()
+	sqliteDatabase, err := openDB()
 	if err != nil {
-		log.Fatal(err)
+		log.Fatal(err.Error())
 	}
+	defer sqliteDatabase.Close()
 
-	dir, exists := os.LookupEnv("TODO_DBFILE")
-	if exists {
-		appPath = dir
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(sqliteDatabase, os.Args[2:]); err != nil {
+			log.Fatal(err.Error())
+		}
+		return
 	}
 
-	const DbName string = "scheduler.db"
-
-	model.DbFile = filepath.Join(filepath.Dir(appPath), DbName)
+	if err := database.Migrate(sqliteDatabase); err != nil {
+		log.Fatal(err.Error())
+	}
 
-	var install bool
-	_, err = os.Stat(model.DbFile)
+	holidays, err := holiday.Load(os.Getenv("TODO_COUNTRY"), os.Getenv("TODO_HOLIDAYS_DIR"))
 	if err != nil {
-		install = true
+		log.Fatal(err.Error())
+	}
+	holidays.Missing = func(year int) {
+		log.Printf("Производственный календарь заполнен на %d–%d годы; в %d году рабочими считаются дни с понедельника по пятницу", holidays.First, holidays.Last, year)
 	}
 
-	sqliteDatabase, _ := sql.Open("sqlite3", model.DbFile)
-	defer sqliteDatabase.Close()
+	cfg := routes.Config{
+		Password: os.Getenv("TODO_PASSWORD"),
+		Clock:    clock.System,
+		Debug:    os.Getenv("TODO_DEBUG") == "true",
+		Calendar: holidays,
+	}
 
-	if install {
-		file, err := os.Create(model.DbFile)
+	if tz, exists := os.LookupEnv("TODO_TZ"); exists && tz != "" {
+		cfg.Location, err = time.LoadLocation(tz)
 		if err != nil {
 			log.Fatal(err.Error())
 		}
-		file.Close()
-		database.CreateTable(sqliteDatabase)
 	}
 
-	database.TaskStorage = &database.TaskStore{Db: sqliteDatabase}
+	// в отладочном режиме текущее время можно зафиксировать для всего сервера
+	if fake, exists := os.LookupEnv("TODO_FAKE_NOW"); exists && fake != "" && cfg.Debug {
+		loc := cfg.Location
+		if loc == nil {
+			loc = time.Local
+		}
+		now, err := clock.Parse(fake, loc)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		cfg.Clock = clock.Fixed(now)
+		log.Printf("Текущее время зафиксировано: %s", now.Format(time.RFC3339))
+	}
 
-	router := routes.NewRouter()
+	// календарь нужен и на следующий год: повторения назначаются вперёд
+	if now := cfg.Clock.Now(); !holidays.Covers(now) || !holidays.Covers(now.AddDate(1, 0, 0)) {
+		log.Printf("Производственный календарь заполнен на %d–%d годы, обновите его (см. README)", holidays.First, holidays.Last)
+	}
+
+	store := database.TaskStore{Db: sqliteDatabase, Clock: cfg.Clock, Calendar: holidays}
+
+	if window, exists := os.LookupEnv("TODO_UNDO_WINDOW"); exists && window != "" {
+		store.UndoWindow, err = time.ParseDuration(window)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+
+	server := routes.NewServer(store, cfg)
 
 	port, exists := os.LookupEnv("TODO_PORT")
 	if !exists {
@@ -61,7 +106,25 @@ func main() {
 	}
 
 	log.Printf("Приложение запущено на порту %s", port)
-	if err := http.ListenAndServe(":"+port, router); err != nil {
+	if err := http.ListenAndServe(":"+port, server); err != nil {
 		log.Fatal(err.Error())
 	}
 }
+
+func openDB() (*sql.DB, error) {
+	appPath, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	dir, exists := os.LookupEnv("TODO_DBFILE")
+	if exists {
+		appPath = dir
+	}
+
+	const DbName string = "scheduler.db"
+
+	dbFile := filepath.Join(filepath.Dir(appPath), DbName)
+
+	return sql.Open("sqlite3", dbFile)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/PhilippElizarov/go_final_project/internal/database"
+)
+
+const migrateUsage = "использование: main migrate status|up"
+
+func runMigrate(db *sql.DB, args []string) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "status":
+		current, err := database.SchemaVersion(db)
+		if err != nil {
+			return err
+		}
+
+		statuses, err := database.Migrations(db)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Версия схемы: %d, последняя известная: %d\n", current, database.LatestVersion())
+		for _, m := range statuses {
+			appliedAt := m.AppliedAt
+			if appliedAt == "" {
+				appliedAt = "не применена"
+			}
+			fmt.Printf("%4d  %-32s %s\n", m.Version, m.Name, appliedAt)
+		}
+	case "up":
+		if err := database.Migrate(db); err != nil {
+			return err
+		}
+
+		current, err := database.SchemaVersion(db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Схема обновлена до версии %d\n", current)
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// TokenTTL совпадает со сроком жизни cookie, который выставляет web/login.html.
+const TokenTTL = 8 * time.Hour
+
+var (
+	ErrInvalidToken = errors.New("некорректный токен")
+	ErrTokenExpired = errors.New("срок действия токена истёк")
+)
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+type claims struct {
+	IssuedAt  int64 `json:"iat"`
+	ExpiresAt int64 `json:"exp"`
+}
+
+var encoding = base64.RawURLEncoding
+
+// Sign выпускает JWT (HS256), подписанный паролем: при смене пароля
+// все ранее выданные токены перестают приниматься.
+func Sign(password string, now time.Time) (string, error) {
+	h, err := json.Marshal(header{Alg: "HS256", Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	c, err := json.Marshal(claims{IssuedAt: now.Unix(), ExpiresAt: now.Add(TokenTTL).Unix()})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := encoding.EncodeToString(h) + "." + encoding.EncodeToString(c)
+
+	return unsigned + "." + signature(password, unsigned), nil
+}
+
+// Verify проверяет подпись и срок действия токена.
+func Verify(password string, token string, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ErrInvalidToken
+	}
+
+	var h header
+	if err := decode(parts[0], &h); err != nil || h.Alg != "HS256" {
+		return ErrInvalidToken
+	}
+
+	unsigned := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(signature(password, unsigned))) {
+		return ErrInvalidToken
+	}
+
+	var c claims
+	if err := decode(parts[1], &c); err != nil {
+		return ErrInvalidToken
+	}
+
+	if now.Unix() >= c.ExpiresAt {
+		return ErrTokenExpired
+	}
+
+	return nil
+}
+
+// NewFeedToken выпускает случайный токен для подписки на календарь. Токен
+// не истекает; сервер хранит только его FeedTokenHash, поэтому токен можно
+// отозвать, удалив хэш.
+func NewFeedToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// FeedTokenHash возвращает хэш токена подписки, подписанный паролем: при
+// смене пароля прежние токены подписки тоже перестают приниматься.
+func FeedTokenHash(password string, token string) string {
+	return signature(password, "feed."+token)
+}
+
+func signature(password string, unsigned string) string {
+	mac := hmac.New(sha256.New, []byte(password))
+	mac.Write([]byte(unsigned))
+	return encoding.EncodeToString(mac.Sum(nil))
+}
+
+func decode(part string, v any) error {
+	data, err := encoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
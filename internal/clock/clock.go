@@ -0,0 +1,43 @@
+package clock
+
+import (
+	"errors"
+	"time"
+
+	"github.com/PhilippElizarov/go_final_project/internal/model"
+)
+
+// Clock возвращает текущий момент. Сервер и хранилище берут время только
+// через него, чтобы в тестах можно было зафиксировать дату.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// System — часы операционной системы.
+var System Clock = systemClock{}
+
+// Fixed всегда возвращает один и тот же момент.
+type Fixed time.Time
+
+func (f Fixed) Now() time.Time {
+	return time.Time(f)
+}
+
+var ErrInvalidTime = errors.New("некорректное время: ожидается RFC 3339 или 20060102")
+
+// Parse разбирает момент в формате RFC 3339 или дату 20060102 (полночь в поясе loc).
+func Parse(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(model.TimeTemplate, value, loc); err == nil {
+		return t, nil
+	}
+	return time.Time{}, ErrInvalidTime
+}
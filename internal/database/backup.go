@@ -0,0 +1,78 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/PhilippElizarov/go_final_project/internal/model"
+)
+
+// ImportTasks записывает задачи и исключения для их повторений одной
+// транзакцией. При replace прежние задачи удаляются, иначе задачи с
+// совпадающим id перезаписываются, а задачи без id добавляются как новые.
+// Исключение заменяет прежнее исключение задачи на ту же дату.
+func (s TaskStore) ImportTasks(tasks []model.Task, exceptions []model.TaskException, replace bool) error {
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if replace {
+		if _, err = tx.Exec("DELETE FROM scheduler"); err != nil {
+			return err
+		}
+		if _, err = tx.Exec("DELETE FROM task_exceptions"); err != nil {
+			return err
+		}
+	}
+
+	for _, task := range tasks {
+		if task.ID == "" {
+			_, err = tx.Exec("INSERT INTO scheduler (date, time, title, comment, repeat, until, remaining, catchup, shift_base) VALUES (:date, :time, :title, :comment, :repeat, :until, :remaining, :catchup, :shift_base)",
+				sql.Named("date", task.Date),
+				sql.Named("time", task.Time),
+				sql.Named("title", task.Title),
+				sql.Named("comment", task.Comment),
+				sql.Named("repeat", task.Repeat),
+				sql.Named("until", task.Until),
+				sql.Named("remaining", task.Remaining),
+				sql.Named("catchup", task.CatchUp),
+				sql.Named("shift_base", task.ShiftBase))
+		} else {
+			_, err = tx.Exec(`INSERT INTO scheduler (id, date, time, title, comment, repeat, until, remaining, catchup, shift_base) VALUES (:id, :date, :time, :title, :comment, :repeat, :until, :remaining, :catchup, :shift_base)
+				ON CONFLICT (id) DO UPDATE SET date = excluded.date, time = excluded.time, title = excluded.title, comment = excluded.comment, repeat = excluded.repeat,
+				until = excluded.until, remaining = excluded.remaining, catchup = excluded.catchup, shift_base = excluded.shift_base`,
+				sql.Named("id", task.ID),
+				sql.Named("date", task.Date),
+				sql.Named("time", task.Time),
+				sql.Named("title", task.Title),
+				sql.Named("comment", task.Comment),
+				sql.Named("repeat", task.Repeat),
+				sql.Named("until", task.Until),
+				sql.Named("remaining", task.Remaining),
+				sql.Named("catchup", task.CatchUp),
+				sql.Named("shift_base", task.ShiftBase))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, e := range exceptions {
+		if e.Kind == model.ExceptionSkip {
+			e.NewDate, e.Title = "", ""
+		}
+		_, err = tx.Exec(`INSERT INTO task_exceptions (task_id, date, kind, new_date, title) VALUES (:task_id, :date, :kind, :new_date, :title)
+			ON CONFLICT (task_id, date) DO UPDATE SET kind = excluded.kind, new_date = excluded.new_date, title = excluded.title`,
+			sql.Named("task_id", e.TaskID),
+			sql.Named("date", e.Date),
+			sql.Named("kind", e.Kind),
+			sql.Named("new_date", e.NewDate),
+			sql.Named("title", e.Title))
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/PhilippElizarov/go_final_project/internal/model"
+)
+
+func addCompletion(tx *sql.Tx, task model.Task, doneAt time.Time) (int64, error) {
+	res, err := tx.Exec("INSERT INTO task_completions (task_id, date, title, done_at) VALUES (:task_id, :date, :title, :done_at)",
+		sql.Named("task_id", task.ID),
+		sql.Named("date", task.Date),
+		sql.Named("title", task.Title),
+		sql.Named("done_at", doneAt.UTC().Format(time.RFC3339)))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.LastInsertId()
+}
+
+// TaskHistory возвращает отметки о выполнении задачи, в том числе уже удалённой.
+func (s TaskStore) TaskHistory(id string) (model.Completions, error) {
+	rows, err := s.Db.Query("SELECT id, task_id, date, title, done_at FROM task_completions WHERE task_id = :task_id ORDER BY done_at, id",
+		sql.Named("task_id", id))
+	if err != nil {
+		return model.Completions{}, err
+	}
+
+	return scanCompletions(rows)
+}
+
+// GetCompletions возвращает задачи, выполненные не раньше from и раньше to;
+// нулевая граница не ограничивает выборку.
+func (s TaskStore) GetCompletions(from time.Time, to time.Time) (model.Completions, error) {
+	// done_at хранится в RFC 3339 в UTC, поэтому моменты сравниваются как строки
+	fromAt := ""
+	if !from.IsZero() {
+		fromAt = from.UTC().Format(time.RFC3339)
+	}
+
+	toAt := "9999"
+	if !to.IsZero() {
+		toAt = to.UTC().Format(time.RFC3339)
+	}
+
+	rows, err := s.Db.Query("SELECT id, task_id, date, title, done_at FROM task_completions WHERE done_at >= :from AND done_at < :to ORDER BY done_at, id",
+		sql.Named("from", fromAt),
+		sql.Named("to", toAt))
+	if err != nil {
+		return model.Completions{}, err
+	}
+
+	return scanCompletions(rows)
+}
+
+func scanCompletions(rows *sql.Rows) (model.Completions, error) {
+	var completion model.Completion
+	completions := model.Completions{Completions: []model.Completion{}}
+	defer rows.Close()
+
+	for rows.Next() {
+		err := rows.Scan(&completion.ID, &completion.TaskID, &completion.Date, &completion.Title, &completion.DoneAt)
+		if err != nil {
+			return completions, err
+		}
+		completions.Completions = append(completions.Completions, completion)
+	}
+
+	if err := rows.Err(); err != nil {
+		return completions, err
+	}
+
+	return completions, nil
+}
@@ -2,100 +2,182 @@ package database
 
 import (
 	"database/sql"
-	"log"
+	"encoding/base64"
+	"errors"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/PhilippElizarov/go_final_project/internal/clock"
 	"github.com/PhilippElizarov/go_final_project/internal/model"
 	"github.com/PhilippElizarov/go_final_project/internal/nextdate"
 )
 
-const limit = 50
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// taskColumns перечисляет столбцы scheduler в порядке полей scanTask.
+const taskColumns = "id, date, title, comment, repeat, time, until, remaining, catchup, shift_base"
 
 type TaskStore struct {
 	Db *sql.DB
+	// UndoWindow — сколько времени после удаления или выполнения задачи
+	// операцию можно отменить; нулевое значение означает DefaultUndoWindow.
+	UndoWindow time.Duration
+	// Clock задаёт время снимков для отмены; nil означает системные часы.
+	Clock clock.Clock
+	// Calendar — производственный календарь для правил с рабочими днями;
+	// nil означает, что нерабочие только субботы и воскресенья.
+	Calendar nextdate.BusinessCalendar
 }
 
-var TaskStorage *TaskStore
-
-func CreateTable(db *sql.DB) {
-	createSchedulerTableSQL := `CREATE TABLE scheduler (
-		"id" integer NOT NULL PRIMARY KEY AUTOINCREMENT,	
-		"date" CHAR(8), 	
-		"title" VARCHAR(128),
-		"comment" TEXT,
-		"repeat" VARCHAR(128) NULL
-	  );
-	  CREATE INDEX scheduler_date ON scheduler (date);`
-
-	statement, err := db.Prepare(createSchedulerTableSQL)
-	if err != nil {
-		log.Fatal(err.Error())
+func (s TaskStore) now() time.Time {
+	if s.Clock == nil {
+		return clock.System.Now()
 	}
-	statement.Exec()
+	return s.Clock.Now()
 }
 
 func (s TaskStore) DeleteTask(id string) error {
-	task, err := s.GetTaskByID(id)
+	tx, err := s.Db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	_, err = s.Db.Exec("DELETE FROM scheduler WHERE id = :id", sql.Named("id", task.ID))
+	task, err := scanTask(tx.QueryRow("SELECT "+taskColumns+" FROM scheduler WHERE id = :id", sql.Named("id", id)))
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if err = s.addSnapshot(tx, task, opDelete, 0, s.now()); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM scheduler WHERE id = :id", sql.Named("id", task.ID))
+	if err != nil {
+		return err
+	}
+	if err = deleteExceptions(tx, task.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (s TaskStore) DoneTask(id string) error {
-	task, err := s.GetTaskByID(id)
+// DoneTask отмечает задачу выполненной в момент doneAt; следующая дата
+// повторяющейся задачи выбирается в её режиме CatchUp и отсчитывается
+// в часовом поясе doneAt.
+func (s TaskStore) DoneTask(id string, doneAt time.Time) error {
+	tx, err := s.Db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	dateNow := time.Now().Format(model.TimeTemplate)
-	dateNow_, err := time.Parse(model.TimeTemplate, dateNow)
+	task, err := scanTask(tx.QueryRow("SELECT "+taskColumns+" FROM scheduler WHERE id = :id", sql.Named("id", id)))
 	if err != nil {
 		return err
 	}
 
-	if task.Repeat == "" {
-		_, err = s.Db.Exec("DELETE FROM scheduler WHERE id = :id", sql.Named("id", task.ID))
+	var rule nextdate.Rule
+	completed := task
+	if task.Repeat != "" {
+		var exceptions []model.TaskException
+		rule, exceptions, err = s.taskRule(tx, task)
 		if err != nil {
 			return err
 		}
-	} else {
-		task.Date, err = nextdate.NextDate(dateNow_, task.Date, task.Repeat)
+		// в истории остаётся повторение таким, каким оно было переопределено
+		if e, ok := override(exceptions, task.Date); ok {
+			applyOverride(&completed, e)
+		}
+	}
+
+	completionID, err := addCompletion(tx, completed, doneAt)
+	if err != nil {
+		return err
+	}
+
+	if err = s.addSnapshot(tx, task, opDone, completionID, s.now()); err != nil {
+		return err
+	}
+
+	var next time.Time
+	var ok, advanced bool
+	if task.Repeat != "" {
+		start, err := task.Start()
+		if err != nil {
+			return err
+		}
+		mode, err := nextdate.ParseCatchUp(task.CatchUp)
+		if err != nil {
+			return err
+		}
+		before := rule.String()
+		next, rule, ok = mode.Done(doneAt, start, rule)
+		advanced = rule.String() != before
+	}
+
+	// разовая задача и задача, у которой закончились повторения, удаляются;
+	// отметка о выполнении остаётся в истории
+	if !ok || task.Remaining == 1 ||
+		task.Until != "" && next.Format(model.TimeTemplate) > task.Until {
+		_, err = tx.Exec("DELETE FROM scheduler WHERE id = :id", sql.Named("id", task.ID))
 		if err != nil {
 			return err
 		}
-		_, err = s.Db.Exec("UPDATE scheduler SET date = :date, title = :title, comment = :comment, repeat = :repeat WHERE id = :id",
+		if err = deleteExceptions(tx, task.ID); err != nil {
+			return err
+		}
+	} else {
+		// у RRULE с COUNT сохраняется число оставшихся повторений
+		if advanced {
+			task.Repeat = rule.String()
+		}
+		if task.Remaining > 0 {
+			task.Remaining--
+		}
+		task.SetStart(next)
+		task.SetShiftBase(nextdate.ShiftBase(rule, next))
+		_, err = tx.Exec("UPDATE scheduler SET date = :date, time = :time, repeat = :repeat, remaining = :remaining, shift_base = :shift_base WHERE id = :id",
 			sql.Named("date", task.Date),
-			sql.Named("title", task.Title),
-			sql.Named("comment", task.Comment),
+			sql.Named("time", task.Time),
 			sql.Named("repeat", task.Repeat),
+			sql.Named("remaining", task.Remaining),
+			sql.Named("shift_base", task.ShiftBase),
 			sql.Named("id", task.ID))
 		if err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return tx.Commit()
 }
 
+// UpdateTask сохраняет задачу. Если дата и правило не изменились, а
+// shift_base не передан, прежняя дата до переноса на рабочий день остаётся.
 func (s TaskStore) UpdateTask(task model.Task) error {
 	_, err := s.GetTaskByID(task.ID)
 	if err != nil {
 		return err
 	}
 
-	_, err = s.Db.Exec("UPDATE scheduler SET date = :date, title = :title, comment = :comment, repeat = :repeat WHERE id = :id",
+	_, err = s.Db.Exec(`UPDATE scheduler SET
+		shift_base = CASE WHEN :shift_base = '' AND date = :date AND repeat = :repeat THEN shift_base ELSE :shift_base END,
+		date = :date, time = :time, title = :title, comment = :comment, repeat = :repeat, until = :until, remaining = :remaining, catchup = :catchup
+		WHERE id = :id`,
 		sql.Named("date", task.Date),
+		sql.Named("time", task.Time),
 		sql.Named("title", task.Title),
 		sql.Named("comment", task.Comment),
 		sql.Named("repeat", task.Repeat),
+		sql.Named("until", task.Until),
+		sql.Named("remaining", task.Remaining),
+		sql.Named("catchup", task.CatchUp),
+		sql.Named("shift_base", task.ShiftBase),
 		sql.Named("id", task.ID))
 	if err != nil {
 		return err
@@ -105,9 +187,7 @@ func (s TaskStore) UpdateTask(task model.Task) error {
 }
 
 func (s TaskStore) GetTaskByID(id string) (model.Task, error) {
-	var task model.Task
-	row := s.Db.QueryRow("SELECT * FROM scheduler WHERE id = :id", sql.Named("id", id))
-	err := row.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat)
+	task, err := scanTask(s.Db.QueryRow("SELECT "+taskColumns+" FROM scheduler WHERE id = :id", sql.Named("id", id)))
 	if err != nil {
 		return task, err
 	}
@@ -117,11 +197,16 @@ func (s TaskStore) GetTaskByID(id string) (model.Task, error) {
 
 func (s TaskStore) AddTask(task model.Task) (model.Response, error) {
 	var response model.Response
-	res, err := s.Db.Exec("INSERT INTO scheduler (date, title, comment, repeat) VALUES (:date, :title, :comment, :repeat)",
+	res, err := s.Db.Exec("INSERT INTO scheduler (date, time, title, comment, repeat, until, remaining, catchup, shift_base) VALUES (:date, :time, :title, :comment, :repeat, :until, :remaining, :catchup, :shift_base)",
 		sql.Named("date", task.Date),
+		sql.Named("time", task.Time),
 		sql.Named("title", task.Title),
 		sql.Named("comment", task.Comment),
-		sql.Named("repeat", task.Repeat))
+		sql.Named("repeat", task.Repeat),
+		sql.Named("until", task.Until),
+		sql.Named("remaining", task.Remaining),
+		sql.Named("catchup", task.CatchUp),
+		sql.Named("shift_base", task.ShiftBase))
 	if err != nil {
 		return response, err
 	}
@@ -136,43 +221,66 @@ func (s TaskStore) AddTask(task model.Task) (model.Response, error) {
 	return response, nil
 }
 
-func (s TaskStore) GetTasks(search string) (model.Tasks, error) {
-	var task model.Task
+func (s TaskStore) GetTasks(filter model.TaskFilter) (model.Tasks, error) {
 	var tasks model.Tasks
-	var rows *sql.Rows
-	var err error
+	var conditions []string
+	var args []any
 
-	if search == "" {
-		rows, err = s.Db.Query("SELECT * FROM scheduler ORDER BY date LIMIT :limit", sql.Named("limit", limit))
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+
+	if filter.Search != "" {
+		date, err := time.Parse("02.01.2006", filter.Search)
 		if err != nil {
-			return tasks, err
+			conditions = append(conditions, "(title LIKE :search OR comment LIKE :search)")
+			args = append(args, sql.Named("search", `%`+filter.Search+`%`))
+		} else {
+			conditions = append(conditions, "date = :date")
+			args = append(args, sql.Named("date", date.Format(model.TimeTemplate)))
 		}
-	} else {
-		date, err := time.Parse("02.01.2006", search)
+	}
+
+	if filter.Cursor != "" {
+		cursorDate, cursorTime, cursorID, err := decodeCursor(filter.Cursor)
 		if err != nil {
-			search = `%` + search + `%`
-			rows, err = s.Db.Query("SELECT * FROM scheduler WHERE title LIKE :search OR comment LIKE :search ORDER BY date LIMIT :limit",
-				sql.Named("search", search),
-				sql.Named("limit", limit))
-			if err != nil {
-				return tasks, err
-			}
-		} else {
-			rows, err = s.Db.Query("SELECT * FROM scheduler WHERE date = :date LIMIT :limit",
-				sql.Named("date", date.Format(model.TimeTemplate)),
-				sql.Named("limit", limit))
-			if err != nil {
-				return tasks, err
-			}
+			return tasks, err
 		}
+		conditions = append(conditions, "(date, time, id) > (:cursor_date, :cursor_time, :cursor_id)")
+		args = append(args,
+			sql.Named("cursor_date", cursorDate),
+			sql.Named("cursor_time", cursorTime),
+			sql.Named("cursor_id", cursorID))
+	}
+
+	query := "SELECT " + taskColumns + " FROM scheduler"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	// запрашиваем на одну запись больше, чтобы узнать, есть ли следующая страница
+	query += " ORDER BY date, time, id LIMIT :limit"
+	args = append(args, sql.Named("limit", limit+1))
+
+	rows, err := s.Db.Query(query, args...)
+	if err != nil {
+		return tasks, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		err := rows.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat)
+		task, err := scanTask(rows)
 		if err != nil {
 			return tasks, err
 		}
+		if len(tasks.Tasks) == limit {
+			last := tasks.Tasks[limit-1].(model.Task)
+			tasks.NextCursor = encodeCursor(last)
+			break
+		}
 		tasks.Tasks = append(tasks.Tasks, task)
 	}
 
@@ -180,5 +288,70 @@ func (s TaskStore) GetTasks(search string) (model.Tasks, error) {
 		return tasks, err
 	}
 
+	if err := s.applyOverrides(tasks.Tasks); err != nil {
+		return tasks, err
+	}
+
 	return tasks, nil
 }
+
+// курсор кодирует ключ сортировки последней задачи страницы: дату, время и id
+func encodeCursor(task model.Task) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(task.Date + "|" + task.Time + "|" + task.ID))
+}
+
+func decodeCursor(cursor string) (string, string, int64, error) {
+	errCursor := errors.New("некорректный курсор")
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", "", 0, errCursor
+	}
+
+	parts := strings.Split(string(data), "|")
+	if len(parts) != 3 {
+		return "", "", 0, errCursor
+	}
+
+	if _, err := time.Parse(model.TimeTemplate, parts[0]); err != nil {
+		return "", "", 0, errCursor
+	}
+
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return "", "", 0, errCursor
+	}
+
+	return parts[0], parts[1], id, nil
+}
+
+// ForEachTask обходит все задачи по порядку дат, не загружая их в память целиком.
+func (s TaskStore) ForEachTask(fn func(task model.Task) error) error {
+	rows, err := s.Db.Query("SELECT " + taskColumns + " FROM scheduler ORDER BY date, time, id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(task); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTask(row scanner) (model.Task, error) {
+	var task model.Task
+	err := row.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat, &task.Time, &task.Until, &task.Remaining, &task.CatchUp, &task.ShiftBase)
+	return task, err
+}
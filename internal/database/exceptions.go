@@ -0,0 +1,273 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PhilippElizarov/go_final_project/internal/model"
+	"github.com/PhilippElizarov/go_final_project/internal/nextdate"
+)
+
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// TaskExceptions возвращает исключения для повторений задачи по порядку дат.
+func (s TaskStore) TaskExceptions(taskID string) (model.TaskExceptions, error) {
+	exceptions, err := taskExceptions(s.Db, taskID)
+	return model.TaskExceptions{Exceptions: exceptions}, err
+}
+
+func taskExceptions(q querier, taskID string) ([]model.TaskException, error) {
+	rows, err := q.Query("SELECT id, task_id, date, kind, new_date, title FROM task_exceptions WHERE task_id = :task_id ORDER BY date",
+		sql.Named("task_id", taskID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exceptions := []model.TaskException{}
+	for rows.Next() {
+		var e model.TaskException
+		if err := rows.Scan(&e.ID, &e.TaskID, &e.Date, &e.Kind, &e.NewDate, &e.Title); err != nil {
+			return nil, err
+		}
+		exceptions = append(exceptions, e)
+	}
+
+	return exceptions, rows.Err()
+}
+
+// ForEachException обходит исключения всех задач по порядку id задач и дат.
+func (s TaskStore) ForEachException(fn func(e model.TaskException) error) error {
+	rows, err := s.Db.Query("SELECT id, task_id, date, kind, new_date, title FROM task_exceptions ORDER BY task_id, date")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e model.TaskException
+		if err := rows.Scan(&e.ID, &e.TaskID, &e.Date, &e.Kind, &e.NewDate, &e.Title); err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// deleteExceptions удаляет исключения задачи вместе с ней самой.
+func deleteExceptions(tx *sql.Tx, taskID string) error {
+	_, err := tx.Exec("DELETE FROM task_exceptions WHERE task_id = :task_id", sql.Named("task_id", taskID))
+	return err
+}
+
+// parseRule разбирает правило задачи вместе с датой до переноса на рабочий
+// день и производственным календарём хранилища.
+func (s TaskStore) parseRule(task model.Task) (nextdate.Rule, error) {
+	rule, err := nextdate.Parse(task.Repeat)
+	if err != nil {
+		return nil, err
+	}
+	rule = nextdate.WithCalendar(rule, s.Calendar)
+	if task.ShiftBase == "" {
+		return rule, nil
+	}
+	base, err := time.Parse(model.TimeTemplate, task.ShiftBase)
+	if err != nil {
+		return nil, err
+	}
+	return nextdate.WithShiftBase(rule, base), nil
+}
+
+// taskRule разбирает правило задачи с учётом пропущенных повторений.
+func (s TaskStore) taskRule(q querier, task model.Task) (nextdate.Rule, []model.TaskException, error) {
+	rule, err := s.parseRule(task)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	exceptions, err := taskExceptions(q, task.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return nextdate.WithExceptions(rule, model.TaskExceptions{Exceptions: exceptions}.SkipDates()), exceptions, nil
+}
+
+// AddException добавляет исключение для повторения задачи или заменяет
+// прежнее на ту же дату. Если пропускается повторение, на которое задача
+// назначена сейчас, задача переносится на следующее.
+func (s TaskStore) AddException(e model.TaskException) (model.Response, error) {
+	var response model.Response
+
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return response, err
+	}
+	defer tx.Rollback()
+
+	task, err := scanTask(tx.QueryRow("SELECT "+taskColumns+" FROM scheduler WHERE id = :id", sql.Named("id", e.TaskID)))
+	if err != nil {
+		return response, err
+	}
+
+	if task.Repeat == "" {
+		return response, errors.New("Задача не повторяется")
+	}
+
+	rule, err := s.parseRule(task)
+	if err != nil {
+		return response, err
+	}
+
+	start, err := task.Start()
+	if err != nil {
+		return response, err
+	}
+
+	if !nextdate.IsOccurrence(rule, start, e.Date) {
+		return response, errors.New("На дату " + e.Date + " нет повторения задачи")
+	}
+
+	if e.Kind == model.ExceptionSkip {
+		e.NewDate, e.Title = "", ""
+	}
+
+	var id int64
+	err = tx.QueryRow(`INSERT INTO task_exceptions (task_id, date, kind, new_date, title) VALUES (:task_id, :date, :kind, :new_date, :title)
+		ON CONFLICT (task_id, date) DO UPDATE SET kind = excluded.kind, new_date = excluded.new_date, title = excluded.title
+		RETURNING id`,
+		sql.Named("task_id", task.ID),
+		sql.Named("date", e.Date),
+		sql.Named("kind", e.Kind),
+		sql.Named("new_date", e.NewDate),
+		sql.Named("title", e.Title)).Scan(&id)
+	if err != nil {
+		return response, err
+	}
+
+	if e.Kind == model.ExceptionSkip && e.Date == task.Date {
+		skipping, _, err := s.taskRule(tx, task)
+		if err != nil {
+			return response, err
+		}
+		c := nextdate.Occurrences(start, skipping)
+		next, ok := c.After(start)
+		// как и в DoneTask, повторений после пропущенной даты может не остаться
+		if !ok || task.Remaining == 1 || task.Until != "" && next.Format(model.TimeTemplate) > task.Until {
+			return response, errors.New("Повторений больше нет")
+		}
+		if advanced := c.Rule(); advanced.String() != skipping.String() {
+			task.Repeat = advanced.String()
+		}
+		task.SetStart(next)
+		task.SetShiftBase(nextdate.ShiftBase(c.Rule(), next))
+		_, err = tx.Exec("UPDATE scheduler SET date = :date, time = :time, repeat = :repeat, shift_base = :shift_base WHERE id = :id",
+			sql.Named("date", task.Date),
+			sql.Named("time", task.Time),
+			sql.Named("repeat", task.Repeat),
+			sql.Named("shift_base", task.ShiftBase),
+			sql.Named("id", task.ID))
+		if err != nil {
+			return response, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return response, err
+	}
+
+	response.Id = strconv.FormatInt(id, 10)
+
+	return response, nil
+}
+
+func (s TaskStore) DeleteException(id string) error {
+	res, err := s.Db.Exec("DELETE FROM task_exceptions WHERE id = :id", sql.Named("id", id))
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// override возвращает переопределение повторения, назначенного на date.
+func override(exceptions []model.TaskException, date string) (model.TaskException, bool) {
+	for _, e := range exceptions {
+		if e.Kind == model.ExceptionOverride && e.Date == date {
+			return e, true
+		}
+	}
+	return model.TaskException{}, false
+}
+
+// applyOverride показывает задачу так, как пройдёт её текущее повторение.
+func applyOverride(task *model.Task, e model.TaskException) {
+	if e.NewDate != "" {
+		task.Date = e.NewDate
+	}
+	if e.Title != "" {
+		task.Title = e.Title
+	}
+}
+
+// applyOverrides применяет переопределения к текущим повторениям задач списка.
+// Список уже отсортирован и отфильтрован по исходным датам, и переопределение
+// меняет только то, как задача показана.
+func (s TaskStore) applyOverrides(tasks []interface{}) error {
+	var ids []string
+	var args []any
+	for i, item := range tasks {
+		if task := item.(model.Task); task.Repeat != "" {
+			name := "id" + strconv.Itoa(i)
+			ids = append(ids, ":"+name)
+			args = append(args, sql.Named(name, task.ID))
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows, err := s.Db.Query("SELECT task_id, date, new_date, title FROM task_exceptions WHERE kind = '"+model.ExceptionOverride+"' AND task_id IN ("+strings.Join(ids, ", ")+")", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	overrides := make(map[string]model.TaskException)
+	for rows.Next() {
+		var e model.TaskException
+		if err := rows.Scan(&e.TaskID, &e.Date, &e.NewDate, &e.Title); err != nil {
+			return err
+		}
+		overrides[e.TaskID+"|"+e.Date] = e
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i, item := range tasks {
+		task := item.(model.Task)
+		if e, ok := overrides[task.ID+"|"+task.Date]; ok {
+			applyOverride(&task, e)
+			tasks[i] = task
+		}
+	}
+
+	return nil
+}
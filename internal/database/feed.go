@@ -0,0 +1,41 @@
+package database
+
+import (
+	"database/sql"
+)
+
+// ReplaceFeedToken сохраняет хэш нового токена подписки на календарь;
+// прежние токены отзываются.
+func (s TaskStore) ReplaceFeedToken(hash string) error {
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec("DELETE FROM feed_tokens"); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO feed_tokens (hash, created_at) VALUES (:hash, :created_at)",
+		sql.Named("hash", hash),
+		sql.Named("created_at", s.now().Unix()))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RevokeFeedTokens отзывает все токены подписки на календарь.
+func (s TaskStore) RevokeFeedTokens() error {
+	_, err := s.Db.Exec("DELETE FROM feed_tokens")
+	return err
+}
+
+// IsFeedToken сообщает, что токен подписки с хэшем hash действителен.
+func (s TaskStore) IsFeedToken(hash string) (bool, error) {
+	var n int
+	err := s.Db.QueryRow("SELECT COUNT(*) FROM feed_tokens WHERE hash = :hash", sql.Named("hash", hash)).Scan(&n)
+	return n > 0, err
+}
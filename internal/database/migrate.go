@@ -0,0 +1,240 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type migration struct {
+	version int
+	name    string
+	up      string
+}
+
+// migrations применяются строго по возрастанию версии; уже выпущенные
+// миграции не редактируются, изменения схемы добавляются новыми записями.
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create_scheduler",
+		up: `CREATE TABLE IF NOT EXISTS scheduler (
+			"id" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+			"date" CHAR(8),
+			"title" VARCHAR(128),
+			"comment" TEXT,
+			"repeat" VARCHAR(128) NULL
+		);
+		CREATE INDEX IF NOT EXISTS scheduler_date ON scheduler (date);`,
+	},
+	{
+		version: 2,
+		name:    "create_task_completions",
+		up: `CREATE TABLE task_completions (
+			"id" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+			"task_id" INTEGER NOT NULL,
+			"date" CHAR(8),
+			"title" VARCHAR(128),
+			"done_at" VARCHAR(32)
+		);
+		CREATE INDEX task_completions_task_id ON task_completions (task_id);
+		CREATE INDEX task_completions_done_at ON task_completions (done_at);`,
+	},
+	{
+		version: 3,
+		name:    "create_task_snapshots",
+		up: `CREATE TABLE task_snapshots (
+			"id" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+			"task_id" INTEGER NOT NULL,
+			"operation" VARCHAR(16),
+			"task" TEXT,
+			"completion_id" INTEGER NULL,
+			"created_at" INTEGER
+		);
+		CREATE INDEX task_snapshots_task_id ON task_snapshots (task_id);`,
+	},
+	{
+		version: 4,
+		name:    "create_feed_tokens",
+		up: `CREATE TABLE feed_tokens (
+			"id" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+			"hash" CHAR(43) NOT NULL,
+			"created_at" INTEGER NOT NULL
+		);
+		CREATE UNIQUE INDEX feed_tokens_hash ON feed_tokens (hash);`,
+	},
+	{
+		version: 5,
+		name:    "add_scheduler_time",
+		up: `ALTER TABLE scheduler ADD COLUMN "time" CHAR(5) NOT NULL DEFAULT '';
+		DROP INDEX IF EXISTS scheduler_date;
+		CREATE INDEX scheduler_date ON scheduler (date, time);`,
+	},
+	{
+		version: 6,
+		name:    "add_scheduler_shift_base",
+		up:      `ALTER TABLE scheduler ADD COLUMN "shift_base" CHAR(8) NOT NULL DEFAULT '';`,
+	},
+	{
+		version: 7,
+		name:    "create_task_exceptions",
+		up: `CREATE TABLE task_exceptions (
+			"id" integer NOT NULL PRIMARY KEY AUTOINCREMENT,
+			"task_id" INTEGER NOT NULL,
+			"date" CHAR(8) NOT NULL,
+			"kind" VARCHAR(16) NOT NULL,
+			"new_date" CHAR(8) NOT NULL DEFAULT '',
+			"title" VARCHAR(128) NOT NULL DEFAULT ''
+		);
+		CREATE UNIQUE INDEX task_exceptions_task_date ON task_exceptions (task_id, date);`,
+	},
+	{
+		version: 8,
+		name:    "add_scheduler_end",
+		up: `ALTER TABLE scheduler ADD COLUMN "until" CHAR(8) NOT NULL DEFAULT '';
+		ALTER TABLE scheduler ADD COLUMN "remaining" INTEGER NOT NULL DEFAULT 0;`,
+	},
+	{
+		version: 9,
+		name:    "add_scheduler_catchup",
+		up:      `ALTER TABLE scheduler ADD COLUMN "catchup" VARCHAR(32) NOT NULL DEFAULT '';`,
+	},
+}
+
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt string
+}
+
+func LatestVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+func ensureMigrationsTable(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		"version" INTEGER NOT NULL PRIMARY KEY,
+		"name" VARCHAR(128),
+		"applied_at" VARCHAR(32)
+	)`)
+	return err
+}
+
+// migrationsTableExists сообщает, есть ли в базе таблица schema_migrations:
+// чтение статуса не должно создавать её.
+func migrationsTableExists(db *sql.DB) (bool, error) {
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'").Scan(&n)
+	return n > 0, err
+}
+
+// SchemaVersion возвращает номер последней применённой миграции; у базы
+// без таблицы schema_migrations версия 0. База не изменяется.
+func SchemaVersion(db *sql.DB) (int, error) {
+	exists, err := migrationsTableExists(db)
+	if err != nil || !exists {
+		return 0, err
+	}
+
+	var version int
+	err = db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+// Migrations возвращает все известные миграции с отметкой о времени
+// применения. База не изменяется.
+func Migrations(db *sql.DB) ([]MigrationStatus, error) {
+	exists, err := migrationsTableExists(db)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]string)
+	if exists {
+		applied, err = appliedMigrations(db)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var statuses []MigrationStatus
+	for _, m := range migrations {
+		statuses = append(statuses, MigrationStatus{Version: m.version, Name: m.name, AppliedAt: applied[m.version]})
+	}
+
+	return statuses, nil
+}
+
+func appliedMigrations(db *sql.DB) (map[int]string, error) {
+	applied := make(map[int]string)
+	rows, err := db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// Migrate применяет недостающие миграции, каждую в отдельной транзакции.
+// Если база создана более новой версией приложения, возвращается ошибка.
+func Migrate(db *sql.DB) error {
+	if err := ensureMigrationsTable(db); err != nil {
+		return err
+	}
+
+	current, err := SchemaVersion(db)
+	if err != nil {
+		return err
+	}
+
+	if current > LatestVersion() {
+		return fmt.Errorf("версия схемы базы данных %d новее поддерживаемой %d", current, LatestVersion())
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			return fmt.Errorf("миграция %d (%s): %w", m.version, m.name, err)
+		}
+	}
+
+	return nil
+}
+
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(m.up); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (:version, :name, :applied_at)",
+		sql.Named("version", m.version),
+		sql.Named("name", m.name),
+		sql.Named("applied_at", time.Now().Format(time.RFC3339)))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
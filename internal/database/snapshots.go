@@ -0,0 +1,154 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/PhilippElizarov/go_final_project/internal/model"
+)
+
+const DefaultUndoWindow = 5 * time.Minute
+
+const (
+	opDelete = "delete"
+	opDone   = "done"
+)
+
+var ErrNothingToUndo = errors.New("нет операций для отмены")
+
+func (s TaskStore) undoWindow() time.Duration {
+	if s.UndoWindow <= 0 {
+		return DefaultUndoWindow
+	}
+	return s.UndoWindow
+}
+
+// snapshot — состояние задачи вместе с исключениями для её повторений,
+// которые удаляются вместе с задачей.
+type snapshot struct {
+	model.Task
+	Exceptions []model.TaskException `json:"exceptions,omitempty"`
+}
+
+// addSnapshot сохраняет состояние задачи перед удалением или выполнением
+// и удаляет снимки, которые уже нельзя отменить.
+func (s TaskStore) addSnapshot(tx *sql.Tx, task model.Task, operation string, completionID int64, now time.Time) error {
+	exceptions, err := taskExceptions(tx, task.ID)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(snapshot{Task: task, Exceptions: exceptions})
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM task_snapshots WHERE created_at < :cutoff",
+		sql.Named("cutoff", now.Add(-s.undoWindow()).Unix()))
+	if err != nil {
+		return err
+	}
+
+	var completion any
+	if completionID != 0 {
+		completion = completionID
+	}
+
+	_, err = tx.Exec("INSERT INTO task_snapshots (task_id, operation, task, completion_id, created_at) VALUES (:task_id, :operation, :task, :completion_id, :created_at)",
+		sql.Named("task_id", task.ID),
+		sql.Named("operation", operation),
+		sql.Named("task", string(data)),
+		sql.Named("completion_id", completion),
+		sql.Named("created_at", now.Unix()))
+	return err
+}
+
+// UndoTask отменяет последнее удаление или выполнение задачи (любой, если id
+// пустой) и восстанавливает её прежнее состояние вместе с датой.
+func (s TaskStore) UndoTask(id string) (model.Response, error) {
+	var response model.Response
+
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return response, err
+	}
+	defer tx.Rollback()
+
+	var snapshotID int64
+	var data string
+	var completionID sql.NullInt64
+
+	query := "SELECT id, task, completion_id FROM task_snapshots WHERE created_at >= :since"
+	if id != "" {
+		query += " AND task_id = :task_id"
+	}
+	query += " ORDER BY id DESC LIMIT 1"
+
+	row := tx.QueryRow(query,
+		sql.Named("since", s.now().Add(-s.undoWindow()).Unix()),
+		sql.Named("task_id", id))
+	err = row.Scan(&snapshotID, &data, &completionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return response, ErrNothingToUndo
+	}
+	if err != nil {
+		return response, err
+	}
+
+	var saved snapshot
+	if err = json.Unmarshal([]byte(data), &saved); err != nil {
+		return response, err
+	}
+	task := saved.Task
+
+	_, err = tx.Exec("INSERT OR REPLACE INTO scheduler (id, date, time, title, comment, repeat, until, remaining, catchup, shift_base) VALUES (:id, :date, :time, :title, :comment, :repeat, :until, :remaining, :catchup, :shift_base)",
+		sql.Named("id", task.ID),
+		sql.Named("date", task.Date),
+		sql.Named("time", task.Time),
+		sql.Named("title", task.Title),
+		sql.Named("comment", task.Comment),
+		sql.Named("repeat", task.Repeat),
+		sql.Named("until", task.Until),
+		sql.Named("remaining", task.Remaining),
+		sql.Named("catchup", task.CatchUp),
+		sql.Named("shift_base", task.ShiftBase))
+	if err != nil {
+		return response, err
+	}
+
+	// исключения удаляются только вместе с задачей; оставшиеся не меняются
+	for _, e := range saved.Exceptions {
+		_, err = tx.Exec("INSERT OR IGNORE INTO task_exceptions (id, task_id, date, kind, new_date, title) VALUES (:id, :task_id, :date, :kind, :new_date, :title)",
+			sql.Named("id", e.ID),
+			sql.Named("task_id", e.TaskID),
+			sql.Named("date", e.Date),
+			sql.Named("kind", e.Kind),
+			sql.Named("new_date", e.NewDate),
+			sql.Named("title", e.Title))
+		if err != nil {
+			return response, err
+		}
+	}
+
+	if completionID.Valid {
+		_, err = tx.Exec("DELETE FROM task_completions WHERE id = :id", sql.Named("id", completionID.Int64))
+		if err != nil {
+			return response, err
+		}
+	}
+
+	_, err = tx.Exec("DELETE FROM task_snapshots WHERE id = :id", sql.Named("id", snapshotID))
+	if err != nil {
+		return response, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return response, err
+	}
+
+	response.Id = task.ID
+
+	return response, nil
+}
@@ -0,0 +1,134 @@
+package holiday
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PhilippElizarov/go_final_project/internal/model"
+)
+
+//go:embed data/*.txt
+var data embed.FS
+
+// DefaultCountry — календарь, который используется, если страна не указана.
+const DefaultCountry = "ru"
+
+// Calendar — производственный календарь: праздничные дни и рабочие выходные.
+// Календарь заполнен на годы от First до Last; для других лет рабочими
+// считаются дни с понедельника по пятницу.
+type Calendar struct {
+	First, Last int
+	// Missing, если задан, вызывается один раз для каждого года вне
+	// календаря, когда у него впервые спрашивают о рабочем дне.
+	Missing func(year int)
+
+	holidays map[string]bool
+	workdays map[string]bool
+
+	mu     sync.Mutex
+	missed map[int]bool
+}
+
+// Parse читает календарь: по дате 20060102 в строке, праздничные дни как
+// есть, рабочие выходные — с плюсом. Пустые строки и строки с # пропускаются.
+func Parse(r io.Reader) (*Calendar, error) {
+	c := &Calendar{holidays: map[string]bool{}, workdays: map[string]bool{}}
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		days := c.holidays
+		if date, ok := strings.CutPrefix(line, "+"); ok {
+			line, days = date, c.workdays
+		}
+		date, err := time.Parse(model.TimeTemplate, line)
+		if err != nil {
+			return nil, fmt.Errorf("строка %d: некорректная дата %s", n, line)
+		}
+		days[line] = true
+
+		if c.First == 0 || date.Year() < c.First {
+			c.First = date.Year()
+		}
+		c.Last = max(c.Last, date.Year())
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Load загружает календарь страны country из файла <country>.txt в каталоге
+// dir, а если dir пустой — из календарей, встроенных в приложение.
+func Load(country string, dir string) (*Calendar, error) {
+	if country == "" {
+		country = DefaultCountry
+	}
+	name := country + ".txt"
+
+	var f io.ReadCloser
+	var err error
+	if dir != "" {
+		f, err = os.Open(filepath.Join(dir, name))
+	} else {
+		f, err = data.Open("data/" + name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("календарь %s не найден: %w", country, err)
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+// Covers сообщает, что календарь заполнен на год даты date.
+func (c *Calendar) Covers(date time.Time) bool {
+	return date.Year() >= c.First && date.Year() <= c.Last
+}
+
+// IsBusinessDay сообщает, что date — рабочий день.
+func (c *Calendar) IsBusinessDay(date time.Time) bool {
+	if !c.Covers(date) {
+		c.miss(date.Year())
+	}
+
+	key := date.Format(model.TimeTemplate)
+	if c.workdays[key] {
+		return true
+	}
+	if c.holidays[key] {
+		return false
+	}
+	return date.Weekday() != time.Saturday && date.Weekday() != time.Sunday
+}
+
+func (c *Calendar) miss(year int) {
+	if c.Missing == nil {
+		return
+	}
+
+	c.mu.Lock()
+	if c.missed == nil {
+		c.missed = map[int]bool{}
+	}
+	first := !c.missed[year]
+	c.missed[year] = true
+	c.mu.Unlock()
+
+	if first {
+		c.Missing(year)
+	}
+}
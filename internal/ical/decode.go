@@ -0,0 +1,189 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// Item — событие (VEVENT) или задача (VTODO) из загруженного календаря.
+type Item struct {
+	Kind        string
+	UID         string
+	Summary     string
+	Description string
+	Status      string
+	// Start берётся из DTSTART, а для VTODO без него — из DUE.
+	Start time.Time
+	// Timed — у начала указано время суток, а не только дата.
+	Timed bool
+	RRule string
+}
+
+var ErrNoCalendar = errors.New("файл не содержит VCALENDAR")
+
+// Decode разбирает календарь RFC 5545 и возвращает его события и задачи.
+// Остальные компоненты (VTIMEZONE, VALARM и т.п.) пропускаются. Время в UTC
+// переводится в часовой пояс loc.
+func Decode(r io.Reader, loc *time.Location) ([]Item, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []Item
+	var item *Item
+	var depth int
+	var calendar bool
+	var due string
+
+	for _, line := range lines {
+		name, params, value, ok := parseLine(line)
+		if !ok {
+			continue
+		}
+
+		switch name {
+		case "BEGIN":
+			value = strings.ToUpper(value)
+			if value == "VCALENDAR" {
+				calendar = true
+				continue
+			}
+			if item == nil && (value == "VEVENT" || value == "VTODO") {
+				item = &Item{Kind: value}
+				due = ""
+				depth = 0
+				continue
+			}
+			depth++
+			continue
+		case "END":
+			if item == nil {
+				continue
+			}
+			if depth > 0 {
+				depth--
+				continue
+			}
+			if item.Start.IsZero() && due != "" {
+				item.Start, item.Timed = parseDate(due)
+			}
+			items = append(items, *item)
+			item = nil
+			continue
+		}
+
+		// свойства вложенных компонентов (например, VALARM) не относятся к событию
+		if item == nil || depth > 0 {
+			continue
+		}
+
+		switch name {
+		case "UID":
+			item.UID = value
+		case "SUMMARY":
+			item.Summary = unescape(value)
+		case "DESCRIPTION":
+			item.Description = unescape(value)
+		case "STATUS":
+			item.Status = strings.ToUpper(value)
+		case "RRULE":
+			item.RRule = strings.ToUpper(value)
+		case "DTSTART":
+			item.Start, item.Timed = parseDate(dateValue(params, value, loc))
+		case "DUE":
+			due = dateValue(params, value, loc)
+		}
+	}
+
+	if !calendar {
+		return nil, ErrNoCalendar
+	}
+
+	return items, nil
+}
+
+// unfold склеивает свёрнутые строки: продолжение начинается с пробела или табуляции.
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, scanner.Err()
+}
+
+// parseLine разбирает строку вида NAME;PARAM=VALUE:VALUE.
+func parseLine(line string) (string, map[string]string, string, bool) {
+	var head string
+	var value string
+	inQuotes := false
+	for i, c := range line {
+		if c == '"' {
+			inQuotes = !inQuotes
+		}
+		if c == ':' && !inQuotes {
+			head, value = line[:i], line[i+1:]
+			break
+		}
+	}
+	if head == "" {
+		return "", nil, "", false
+	}
+
+	parts := strings.Split(head, ";")
+	params := make(map[string]string)
+	for _, p := range parts[1:] {
+		k, v, found := strings.Cut(p, "=")
+		if found {
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+
+	return strings.ToUpper(parts[0]), params, value, true
+}
+
+// dateValue приводит DATE-TIME в UTC к поясу loc; время с TZID
+// и «плавающее» время берутся по показаниям часов календаря.
+func dateValue(params map[string]string, value string, loc *time.Location) string {
+	if strings.HasSuffix(value, "Z") && params["TZID"] == "" {
+		t, err := time.Parse(dateTimeTemplate, value)
+		if err == nil {
+			return t.In(loc).Format(localTemplate)
+		}
+	}
+	return strings.TrimSuffix(value, "Z")
+}
+
+// parseDate разбирает DATE или DATE-TIME и сообщает, было ли указано время.
+func parseDate(value string) (time.Time, bool) {
+	if t, err := time.Parse(localTemplate, value); err == nil {
+		return t, true
+	}
+	t, err := time.Parse(dateTemplate, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, false
+}
+
+func unescape(s string) string {
+	return strings.NewReplacer(
+		`\\`, `\`,
+		`\;`, ";",
+		`\,`, ",",
+		`\n`, "\n",
+		`\N`, "\n",
+	).Replace(s)
+}
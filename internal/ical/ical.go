@@ -0,0 +1,130 @@
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const dateTemplate = "20060102"
+
+const dateTimeTemplate = "20060102T150405Z"
+
+// localTemplate — «плавающее» время без часового пояса.
+const localTemplate = "20060102T150405"
+
+// Event — событие (VEVENT) в календаре RFC 5545: на весь день или,
+// если Timed, начинающееся в указанное время.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Start       time.Time
+	Timed       bool
+	RRule       string
+	RDates      []time.Time
+	ExDates     []time.Time
+}
+
+// Encode записывает календарь с событиями в формате RFC 5545.
+func Encode(w io.Writer, events []Event, stamp time.Time) error {
+	bw := bufio.NewWriter(w)
+	e := encoder{w: bw}
+
+	e.line("BEGIN:VCALENDAR")
+	e.line("VERSION:2.0")
+	e.line("PRODID:-//go_final_project//scheduler//RU")
+	e.line("CALSCALE:GREGORIAN")
+	e.line("X-WR-CALNAME:" + escape("Планировщик задач"))
+
+	for _, event := range events {
+		e.line("BEGIN:VEVENT")
+		e.line("UID:" + event.UID)
+		e.line("DTSTAMP:" + stamp.UTC().Format(dateTimeTemplate))
+		if event.Timed {
+			e.line("DTSTART:" + event.Start.Format(localTemplate))
+		} else {
+			e.line("DTSTART;VALUE=DATE:" + event.Start.Format(dateTemplate))
+			e.line("DTEND;VALUE=DATE:" + event.Start.AddDate(0, 0, 1).Format(dateTemplate))
+		}
+		e.line("SUMMARY:" + escape(event.Summary))
+		if event.Description != "" {
+			e.line("DESCRIPTION:" + escape(event.Description))
+		}
+		if event.RRule != "" {
+			e.line("RRULE:" + event.RRule)
+		}
+		if len(event.RDates) > 0 {
+			layout, value := dateTemplate, "DATE"
+			if event.Timed {
+				layout, value = localTemplate, "DATE-TIME"
+			}
+			dates := make([]string, 0, len(event.RDates))
+			for _, d := range event.RDates {
+				dates = append(dates, d.Format(layout))
+			}
+			e.line("RDATE;VALUE=" + value + ":" + strings.Join(dates, ","))
+		}
+		if len(event.ExDates) > 0 {
+			layout, value := dateTemplate, "DATE"
+			if event.Timed {
+				layout, value = localTemplate, "DATE-TIME"
+			}
+			dates := make([]string, 0, len(event.ExDates))
+			for _, d := range event.ExDates {
+				dates = append(dates, d.Format(layout))
+			}
+			e.line("EXDATE;VALUE=" + value + ":" + strings.Join(dates, ","))
+		}
+		e.line("END:VEVENT")
+	}
+
+	e.line("END:VCALENDAR")
+
+	if e.err != nil {
+		return e.err
+	}
+	return bw.Flush()
+}
+
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+// line записывает строку содержимого, сворачивая её по 75 октетов
+// без разрыва символов UTF-8.
+func (e *encoder) line(s string) {
+	if e.err != nil {
+		return
+	}
+
+	const limit = 75
+	width := limit
+	for len(s) > width {
+		cut := width
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		if _, e.err = e.w.WriteString(s[:cut] + "\r\n "); e.err != nil {
+			return
+		}
+		s = s[cut:]
+		// продолжение начинается с пробела, который тоже занимает октет
+		width = limit - 1
+	}
+
+	_, e.err = e.w.WriteString(s + "\r\n")
+}
+
+func escape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
@@ -0,0 +1,447 @@
+package ical
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PhilippElizarov/go_final_project/internal/model"
+	"github.com/PhilippElizarov/go_final_project/internal/nextdate"
+)
+
+// MaxExpanded ограничивает число дат, перечисляемых в RDATE для правил,
+// которые нельзя точно выразить через RRULE.
+const MaxExpanded = 100
+
+// ExpandHorizon — на сколько вперёд от даты задачи перечисляются повторения.
+const ExpandHorizon = 2 // лет
+
+var weekDays = []string{"", "MO", "TU", "WE", "TH", "FR", "SA", "SU"}
+
+func TaskUID(id string) string {
+	return "task-" + id + "@go_final_project"
+}
+
+// TaskEvent переводит задачу в событие. Правило повторения переводится
+// в RRULE, если смысл совпадает в точности, иначе ближайшие повторения
+// вычисляются по правилу и перечисляются в RDATE. Рабочие дни для правил
+// b, +b, -b и !b определяются по календарю cal.
+func TaskEvent(task model.Task, cal nextdate.BusinessCalendar) (Event, error) {
+	start, err := task.Start()
+	if err != nil {
+		return Event{}, err
+	}
+
+	event := Event{
+		UID:         TaskUID(task.ID),
+		Summary:     task.Title,
+		Description: task.Comment,
+		Start:       start,
+		Timed:       task.Time != "",
+	}
+
+	if task.Repeat == "" {
+		return event, nil
+	}
+
+	// задача с некорректным правилом выгружается как разовая
+	rule, err := nextdate.Parse(task.Repeat)
+	if err != nil {
+		return event, nil
+	}
+	rule = nextdate.WithCalendar(rule, cal)
+	if base, err := time.Parse(model.TimeTemplate, task.ShiftBase); err == nil {
+		rule = nextdate.WithShiftBase(rule, base)
+	}
+
+	// правила h и min повторяются в течение дня, поэтому событие не может быть на весь день
+	event.Timed = event.Timed || nextdate.Timed(rule)
+
+	if rrule, ok := toRRule(rule, start); ok {
+		if end, ok := rruleEnd(task, rule, event.Timed); ok {
+			event.RRule = rrule + end
+			return event, nil
+		}
+	}
+
+	event.RDates = expand(start, rule)
+	// повторения после окончания не выгружаются; первое повторение — сама задача
+	if task.Until != "" {
+		event.RDates = slices.DeleteFunc(event.RDates, func(d time.Time) bool {
+			return d.Format(model.TimeTemplate) > task.Until
+		})
+	}
+	if task.Remaining > 0 && len(event.RDates) > task.Remaining-1 {
+		event.RDates = event.RDates[:task.Remaining-1]
+	}
+
+	return event, nil
+}
+
+// rruleEnd переводит окончание повторений задачи в COUNT или UNTIL;
+// false, если оба условия не выразить в одном RRULE.
+func rruleEnd(task model.Task, rule nextdate.Rule, timed bool) (string, bool) {
+	if task.Until == "" && task.Remaining == 0 {
+		return "", true
+	}
+	if r, ok := rule.(nextdate.RRule); ok && (r.Count > 0 || !r.Until.IsZero()) {
+		return "", false
+	}
+	switch {
+	case task.Until != "" && task.Remaining > 0:
+		return "", false
+	case task.Remaining > 0:
+		return ";COUNT=" + strconv.Itoa(task.Remaining), true
+	case timed:
+		// при DTSTART с временем UNTIL тоже должен быть с временем
+		return ";UNTIL=" + task.Until + "T235959", true
+	}
+	return ";UNTIL=" + task.Until, true
+}
+
+// Exclude убирает из повторений события пропущенные даты skip (в формате
+// model.TimeTemplate): при RRULE они перечисляются в EXDATE, а из RDATE
+// просто удаляются.
+func Exclude(event Event, skip []string) Event {
+	if len(skip) == 0 {
+		return event
+	}
+
+	if event.RRule == "" {
+		event.RDates = slices.DeleteFunc(event.RDates, func(d time.Time) bool {
+			return slices.Contains(skip, d.Format(model.TimeTemplate))
+		})
+		return event
+	}
+
+	for _, date := range skip {
+		day, err := time.Parse(model.TimeTemplate, date)
+		if err != nil {
+			continue
+		}
+		start := event.Start
+		event.ExDates = append(event.ExDates, time.Date(day.Year(), day.Month(), day.Day(),
+			start.Hour(), start.Minute(), 0, 0, start.Location()))
+	}
+
+	return event
+}
+
+func toRRule(rule nextdate.Rule, start time.Time) (string, bool) {
+	switch r := rule.(type) {
+	case nextdate.RRule:
+		return r.String(), true
+	case nextdate.MinutelyRule:
+		return "FREQ=MINUTELY;INTERVAL=" + strconv.Itoa(r.Interval), true
+	case nextdate.HourlyRule:
+		return "FREQ=HOURLY;INTERVAL=" + strconv.Itoa(r.Interval), true
+	case nextdate.DailyRule:
+		return "FREQ=DAILY;INTERVAL=" + strconv.Itoa(r.Interval), true
+	case nextdate.FloatingRule:
+		// до выполнения плавающее правило повторяется с постоянным интервалом;
+		// у m+ конец месяца считается иначе, чем в RRULE
+		switch r.Unit {
+		case "d":
+			return "FREQ=DAILY;INTERVAL=" + strconv.Itoa(r.Interval), true
+		case "w":
+			return "FREQ=WEEKLY;INTERVAL=" + strconv.Itoa(r.Interval), true
+		}
+		return "", false
+	case nextdate.YearlyRule:
+		// nextdate переносит 29 февраля на 1 марта, а RRULE пропускает невисокосные годы
+		if start.Month() == time.February && start.Day() == 29 {
+			return "", false
+		}
+		return "FREQ=YEARLY", true
+	case nextdate.WeeklyRule:
+		var days []string
+		for _, day := range r.Days {
+			days = append(days, weekDays[day])
+		}
+		if r.Interval > 1 {
+			// RRULE тоже отсчитывает недели от DTSTART, начиная их с понедельника
+			return "FREQ=WEEKLY;INTERVAL=" + strconv.Itoa(r.Interval) + ";WKST=MO;BYDAY=" + strings.Join(days, ","), true
+		}
+		return "FREQ=WEEKLY;BYDAY=" + strings.Join(days, ","), true
+	case nextdate.MonthlyRule:
+		// BYMONTHDAY и BYDAY в RRULE пересекаются, а в nextdate объединяются
+		if len(r.Days) > 0 && len(r.WeekDays) > 0 {
+			return "", false
+		}
+		rrule := "FREQ=MONTHLY;BYMONTHDAY=" + joinInts(r.Days)
+		if len(r.WeekDays) > 0 {
+			var days []string
+			for _, day := range r.WeekDays {
+				days = append(days, strconv.Itoa(day.N)+weekDays[day.Day])
+			}
+			rrule = "FREQ=MONTHLY;BYDAY=" + strings.Join(days, ",")
+		}
+		if len(r.Months) > 0 {
+			rrule += ";BYMONTH=" + joinInts(r.Months)
+		}
+		return rrule, true
+	}
+
+	return "", false
+}
+
+func joinInts(nums []int) string {
+	s := make([]string, len(nums))
+	for i, num := range nums {
+		s[i] = strconv.Itoa(num)
+	}
+	return strings.Join(s, ",")
+}
+
+func expand(start time.Time, rule nextdate.Rule) []time.Time {
+	horizon := start.AddDate(ExpandHorizon, 0, 0)
+
+	var dates []time.Time
+	c := nextdate.Occurrences(start, rule)
+	c.Next() // сама start — DTSTART события
+	for len(dates) < MaxExpanded {
+		next, ok := c.Next()
+		if !ok || next.After(horizon) {
+			break
+		}
+		dates = append(dates, next)
+	}
+
+	return dates
+}
+
+var ErrUnsupportedRRule = errors.New("правило RRULE не поддерживается")
+
+// Repeat — правило повторения задачи, полученное из RRULE: COUNT и UNTIL
+// становятся полями remaining и until задачи.
+type Repeat struct {
+	Rule      string
+	Until     string
+	Remaining int
+}
+
+// FromRRule переводит RRULE в правило повторения nextdate, если оно
+// выражается им без потери смысла. start — DTSTART события.
+func FromRRule(rrule string, start time.Time) (Repeat, error) {
+	var repeat Repeat
+
+	parts := make(map[string]string)
+	for _, p := range strings.Split(strings.TrimPrefix(rrule, "RRULE:"), ";") {
+		k, v, found := strings.Cut(p, "=")
+		if !found {
+			return repeat, fmt.Errorf("%w: %s", ErrUnsupportedRRule, rrule)
+		}
+		parts[strings.ToUpper(k)] = strings.ToUpper(v)
+	}
+
+	unsupported := func(reason string) (Repeat, error) {
+		return Repeat{}, fmt.Errorf("%w: %s", ErrUnsupportedRRule, reason)
+	}
+
+	count, hasCount := parts["COUNT"]
+	until, hasUntil := parts["UNTIL"]
+	delete(parts, "COUNT")
+	delete(parts, "UNTIL")
+	switch {
+	case hasCount && hasUntil:
+		return unsupported("COUNT вместе с UNTIL")
+	case hasCount:
+		num, err := strconv.Atoi(count)
+		if err != nil || num < 1 {
+			return unsupported("COUNT=" + count)
+		}
+		repeat.Remaining = num
+	case hasUntil:
+		date, ok := untilDate(until, start, parts["FREQ"])
+		if !ok {
+			return unsupported("UNTIL=" + until)
+		}
+		repeat.Until = date
+	}
+
+	rule, err := fromRRule(parts, start)
+	if err != nil {
+		return Repeat{}, err
+	}
+	repeat.Rule = rule
+
+	return repeat, nil
+}
+
+// untilDate переводит UNTIL в последнюю дату повторений задачи. Повторения
+// по дням приходятся на время суток start, поэтому UNTIL раньше него
+// исключает свой день; у повторений в течение дня UNTIL с временем
+// выражается датой, только если это конец дня.
+func untilDate(until string, start time.Time, freq string) (string, bool) {
+	if day, err := time.Parse(model.TimeTemplate, until); err == nil {
+		return day.Format(model.TimeTemplate), true
+	}
+
+	t, err := time.Parse(dateTimeTemplate, until)
+	if err == nil {
+		t = t.In(start.Location())
+	} else if t, err = time.ParseInLocation(localTemplate, until, start.Location()); err != nil {
+		return "", false
+	}
+
+	clock := func(t time.Time) int { return t.Hour()*3600 + t.Minute()*60 + t.Second() }
+	switch {
+	case t.Hour() == 23 && t.Minute() == 59:
+	case freq == "HOURLY" || freq == "MINUTELY":
+		return "", false
+	case clock(t) < clock(start):
+		t = t.AddDate(0, 0, -1)
+	}
+
+	return t.Format(model.TimeTemplate), true
+}
+
+// fromRRule переводит части RRULE без COUNT и UNTIL в правило nextdate.
+func fromRRule(parts map[string]string, start time.Time) (string, error) {
+	unsupported := func(reason string) (string, error) {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedRRule, reason)
+	}
+
+	interval := 1
+	if v, ok := parts["INTERVAL"]; ok {
+		num, err := strconv.Atoi(v)
+		if err != nil || num < 1 {
+			return unsupported("INTERVAL=" + v)
+		}
+		interval = num
+	}
+
+	allowed := map[string]bool{"FREQ": true, "INTERVAL": true, "WKST": true}
+	only := func(keys ...string) (string, bool) {
+		for _, k := range keys {
+			allowed[k] = true
+		}
+		for k := range parts {
+			if !allowed[k] {
+				return k, false
+			}
+		}
+		return "", true
+	}
+
+	switch parts["FREQ"] {
+	case "MINUTELY", "HOURLY", "DAILY":
+		if k, ok := only(); !ok {
+			return unsupported(k)
+		}
+		rule, limit := "d", 400
+		switch parts["FREQ"] {
+		case "MINUTELY":
+			rule, limit = "min", 1440
+		case "HOURLY":
+			rule, limit = "h", 168
+		}
+		if interval > limit {
+			return unsupported("INTERVAL больше " + strconv.Itoa(limit))
+		}
+		return rule + " " + strconv.Itoa(interval), nil
+	case "WEEKLY":
+		if k, ok := only("BYDAY"); !ok {
+			return unsupported(k)
+		}
+		// nextdate начинает неделю с понедельника, поэтому другое WKST меняет смысл INTERVAL
+		if wkst, ok := parts["WKST"]; interval != 1 && ok && wkst != "MO" {
+			return unsupported("WKST=" + wkst)
+		}
+		if interval > nextdate.MaxWeekInterval {
+			return unsupported("INTERVAL больше " + strconv.Itoa(nextdate.MaxWeekInterval))
+		}
+		suffix := ""
+		if interval != 1 {
+			suffix = " /" + strconv.Itoa(interval)
+		}
+		byDay, ok := parts["BYDAY"]
+		if !ok {
+			return "w " + strconv.Itoa(weekDayNum(start.Weekday())) + suffix, nil
+		}
+		var days []string
+		for _, day := range strings.Split(byDay, ",") {
+			num := slices.Index(weekDays, day)
+			if num < 1 {
+				return unsupported("BYDAY=" + byDay)
+			}
+			days = append(days, strconv.Itoa(num))
+		}
+		return "w " + strings.Join(days, ",") + suffix, nil
+	case "MONTHLY", "YEARLY":
+		if k, ok := only("BYMONTHDAY", "BYMONTH", "BYDAY"); !ok {
+			return unsupported(k)
+		}
+		if interval != 1 {
+			return unsupported("INTERVAL для FREQ=" + parts["FREQ"])
+		}
+
+		days, hasDays := parts["BYMONTHDAY"]
+		months, hasMonths := parts["BYMONTH"]
+
+		if byDay, ok := parts["BYDAY"]; ok {
+			// без BYMONTH ежегодное BYDAY считает дни недели от начала года
+			if hasDays || parts["FREQ"] == "YEARLY" && !hasMonths {
+				return unsupported("BYDAY=" + byDay)
+			}
+			days, ok = ordinalWeekDays(byDay)
+			if !ok {
+				return unsupported("BYDAY=" + byDay)
+			}
+			hasDays = true
+		}
+
+		// ежегодное повторение в день начала — это правило y
+		if parts["FREQ"] == "YEARLY" && !hasDays && !hasMonths &&
+			!(start.Month() == time.February && start.Day() == 29) {
+			return "y", nil
+		}
+		// без BYMONTH ежегодное правило повторяется в месяц начала
+		if parts["FREQ"] == "YEARLY" && !hasMonths && !hasDays {
+			months = strconv.Itoa(int(start.Month()))
+		}
+		if !hasDays {
+			days = strconv.Itoa(start.Day())
+		}
+
+		repeat := "m " + days
+		if months != "" {
+			repeat += " " + months
+		}
+		rule, err := nextdate.Parse(repeat)
+		if err != nil {
+			return unsupported(err.Error())
+		}
+		return rule.String(), nil
+	}
+
+	return unsupported("FREQ=" + parts["FREQ"])
+}
+
+// ordinalWeekDays переводит BYDAY вида 1MO,-1FR в дни недели правила m
+// (1:1,-1:5); дни недели без порядкового номера не поддерживаются.
+func ordinalWeekDays(byDay string) (string, bool) {
+	var days []string
+	for _, item := range strings.Split(byDay, ",") {
+		if len(item) < 3 {
+			return "", false
+		}
+		day := slices.Index(weekDays, item[len(item)-2:])
+		n, err := strconv.Atoi(item[:len(item)-2])
+		if day < 1 || err != nil {
+			return "", false
+		}
+		days = append(days, strconv.Itoa(n)+":"+strconv.Itoa(day))
+	}
+	return strings.Join(days, ","), true
+}
+
+func weekDayNum(day time.Weekday) int {
+	if day == time.Sunday {
+		return 7
+	}
+	return int(day)
+}
@@ -1,22 +1,171 @@
 package model
 
+import "time"
+
 const TimeTemplate string = "20060102"
 
-var DbFile string
+// ClockTemplate — формат необязательного времени суток задачи.
+const ClockTemplate string = "15:04"
 
 type Task struct {
 	ID      string `json:"id"`
 	Date    string `json:"date"`
+	Time    string `json:"time,omitempty"`
 	Title   string `json:"title"`
 	Comment string `json:"comment"`
 	Repeat  string `json:"repeat"`
+	// Until — последняя дата (включительно), на которую может прийтись
+	// повторение; пустая строка — без ограничения.
+	Until string `json:"until,omitempty"`
+	// Remaining — сколько раз осталось выполнить повторяющуюся задачу,
+	// считая текущее повторение; 0 — без ограничения.
+	Remaining int `json:"remaining,omitempty"`
+	// CatchUp — как выполнение просроченной задачи выбирает следующую дату
+	// (значения nextdate.CatchUp); пустая строка — skip-to-future.
+	CatchUp string `json:"catchup,omitempty"`
+	// ShiftBase — дата повторения по правилу с модификатором +b, -b или !b
+	// до переноса на рабочий день; пустая строка — дата не переносилась.
+	ShiftBase string `json:"shift_base,omitempty"`
+	// RepeatText — описание правила повторения для ответов API, в базе не хранится.
+	RepeatText string `json:"repeat_text,omitempty"`
+}
+
+// Start возвращает дату задачи вместе с временем суток, если оно задано.
+func (t Task) Start() (time.Time, error) {
+	if t.Time == "" {
+		return time.Parse(TimeTemplate, t.Date)
+	}
+	return time.Parse(TimeTemplate+" "+ClockTemplate, t.Date+" "+t.Time)
+}
+
+// SetStart записывает дату задачи, а время суток — если оно уже было
+// задано или если start приходится не на полночь.
+func (t *Task) SetStart(start time.Time) {
+	t.Date = start.Format(TimeTemplate)
+	if t.Time != "" || start.Hour() != 0 || start.Minute() != 0 {
+		t.Time = start.Format(ClockTemplate)
+	}
+}
+
+// SetShiftBase записывает дату повторения до переноса на рабочий день;
+// нулевое время означает, что дата задачи не переносилась.
+func (t *Task) SetShiftBase(base time.Time) {
+	t.ShiftBase = ""
+	if !base.IsZero() {
+		t.ShiftBase = base.Format(TimeTemplate)
+	}
 }
 
 type Response struct {
 	Id    string `json:"id,omitempty"`
+	Token string `json:"token,omitempty"`
 	Error string `json:"error,omitempty"`
 }
 
+type SignIn struct {
+	Password string `json:"password"`
+}
+
 type Tasks struct {
-	Tasks []interface{} `json:"tasks"`
+	Tasks      []interface{} `json:"tasks"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// TaskFilter задаёт поиск и страницу в списке задач.
+// Cursor — непрозрачное значение next_cursor из предыдущего ответа.
+type TaskFilter struct {
+	Search string
+	Limit  int
+	Cursor string
+}
+
+// Completion — запись о выполнении задачи: Date — дата, на которую была
+// назначена задача, DoneAt — момент отметки о выполнении в формате RFC 3339.
+type Completion struct {
+	ID     string `json:"id"`
+	TaskID string `json:"task_id"`
+	Date   string `json:"date"`
+	Title  string `json:"title"`
+	DoneAt string `json:"done_at"`
+}
+
+type Completions struct {
+	Completions []Completion `json:"completions"`
+}
+
+const (
+	ExceptionSkip     = "skip"
+	ExceptionOverride = "override"
+)
+
+// TaskException — исключение для одного повторения задачи, назначенного на
+// дату Date: skip пропускает повторение, override переносит его на NewDate
+// и (или) меняет заголовок на Title.
+type TaskException struct {
+	ID      string `json:"id"`
+	TaskID  string `json:"task_id"`
+	Date    string `json:"date"`
+	Kind    string `json:"kind"`
+	NewDate string `json:"new_date,omitempty"`
+	Title   string `json:"title,omitempty"`
+}
+
+type TaskExceptions struct {
+	Exceptions []TaskException `json:"exceptions"`
+}
+
+// SkipDates возвращает даты пропущенных повторений.
+func (e TaskExceptions) SkipDates() []string {
+	var dates []string
+	for _, exception := range e.Exceptions {
+		if exception.Kind == ExceptionSkip {
+			dates = append(dates, exception.Date)
+		}
+	}
+	return dates
+}
+
+const (
+	ImportCreated     = "created"
+	ImportSkipped     = "skipped"
+	ImportUnsupported = "unsupported"
+)
+
+// ImportItem — результат импорта одного события или задачи календаря.
+type ImportItem struct {
+	UID     string `json:"uid,omitempty"`
+	Summary string `json:"summary,omitempty"`
+	Status  string `json:"status"`
+	ID      string `json:"id,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+type ImportReport struct {
+	Created     int          `json:"created"`
+	Skipped     int          `json:"skipped"`
+	Unsupported int          `json:"unsupported"`
+	Items       []ImportItem `json:"items"`
+}
+
+type ImportResult struct {
+	Imported int `json:"imported"`
+}
+
+// Occurrence — дата (и время, если оно есть) одного повторения задачи.
+type Occurrence struct {
+	Date string `json:"date"`
+	Time string `json:"time,omitempty"`
+}
+
+// RepeatValidation — результат проверки правила повторения. Для
+// некорректного правила Position указывает номер символа (с нуля),
+// с которого начинается ошибочный фрагмент Token.
+type RepeatValidation struct {
+	Valid    bool   `json:"valid"`
+	Rule     string `json:"rule,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Position *int   `json:"position,omitempty"`
+	Token    string `json:"token,omitempty"`
 }
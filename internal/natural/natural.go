@@ -0,0 +1,288 @@
+package natural
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PhilippElizarov/go_final_project/internal/nextdate"
+)
+
+var ErrNotRecognized = errors.New("не удалось распознать")
+
+const (
+	unitMinute = "minute"
+	unitHour   = "hour"
+	unitDay    = "day"
+	unitWeek   = "week"
+	unitMonth  = "month"
+	unitYear   = "year"
+)
+
+var units = map[string]string{
+	"minute": unitMinute, "minutes": unitMinute, "min": unitMinute,
+	"минута": unitMinute, "минуту": unitMinute, "минуты": unitMinute, "минут": unitMinute,
+	"hour": unitHour, "hours": unitHour,
+	"час": unitHour, "часа": unitHour, "часов": unitHour,
+	"day": unitDay, "days": unitDay,
+	"день": unitDay, "дня": unitDay, "дней": unitDay, "сутки": unitDay,
+	"week": unitWeek, "weeks": unitWeek,
+	"неделя": unitWeek, "неделю": unitWeek, "недели": unitWeek, "недель": unitWeek,
+	"month": unitMonth, "months": unitMonth,
+	"месяц": unitMonth, "месяца": unitMonth, "месяцев": unitMonth,
+	"year": unitYear, "years": unitYear,
+	"год": unitYear, "года": unitYear, "лет": unitYear,
+}
+
+// английские дни недели узнаются по полному названию, сокращению из трёх
+// букв или форме множественного числа
+var weekDaysEn = []string{"", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday"}
+
+// русские дни недели узнаются по основе слова в любом падеже
+var weekDaysRu = []string{"", "понедельник", "вторник", "сред", "четверг", "пятниц", "суббот", "воскресень"}
+
+// служебные слова, которые не влияют на смысл фразы
+var fillers = map[string]bool{
+	"on": true, "at": true, "and": true, "this": true, "next": true, "a": true, "an": true,
+	"в": true, "во": true, "по": true, "и": true,
+	"следующий": true, "следующую": true, "следующее": true, "следующая": true,
+	"ближайший": true, "ближайшую": true, "ближайшее": true, "ближайшая": true,
+}
+
+var businessWords = map[string]bool{
+	"business": true, "working": true, "рабочий": true, "рабочих": true, "рабочие": true,
+}
+
+func fields(input string) []string {
+	input = strings.ToLower(input)
+	input = strings.NewReplacer(",", " ", ";", " ").Replace(input)
+	return strings.Fields(input)
+}
+
+func weekDay(word string) (int, bool) {
+	for num := 1; num <= 7; num++ {
+		en := weekDaysEn[num]
+		if len(word) >= 3 && strings.HasPrefix(en, word) || word == en+"s" {
+			return num, true
+		}
+		if strings.HasPrefix(word, weekDaysRu[num]) {
+			return num, true
+		}
+	}
+	return 0, false
+}
+
+// weekDays собирает дни недели из слов; false, если встретилось что-то другое.
+func weekDays(words []string) ([]int, bool) {
+	var days []int
+	for _, word := range words {
+		if fillers[word] {
+			continue
+		}
+		day, ok := weekDay(word)
+		if !ok {
+			return nil, false
+		}
+		days = append(days, day)
+	}
+	return days, len(days) > 0
+}
+
+func weekDayNum(day time.Weekday) int {
+	if day == time.Sunday {
+		return 7
+	}
+	return int(day)
+}
+
+// nextWeekDay возвращает ближайший день недели day после date; если
+// inclusive, подходит и сама date.
+func nextWeekDay(date time.Time, day int, inclusive bool) time.Time {
+	if !inclusive {
+		date = date.AddDate(0, 0, 1)
+	}
+	for weekDayNum(date.Weekday()) != day {
+		date = date.AddDate(0, 0, 1)
+	}
+	return date
+}
+
+// count отделяет от слов необязательное число перед единицей измерения.
+func count(words []string) (int, []string) {
+	if len(words) == 0 {
+		return 1, words
+	}
+	if num, err := strconv.Atoi(words[0]); err == nil {
+		return num, words[1:]
+	}
+	if words[0] == "other" {
+		return 2, words[1:]
+	}
+	return 1, words
+}
+
+func notRecognized(input string) error {
+	return fmt.Errorf("%w: %q", ErrNotRecognized, input)
+}
+
+// ParseDate переводит фразу вроде «завтра», «через 3 дня» или «next friday»
+// в дату относительно now. Названный день недели — ближайший после сегодняшнего.
+func ParseDate(input string, now time.Time) (time.Time, error) {
+	words := fields(input)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch strings.Join(words, " ") {
+	case "today", "сегодня":
+		return today, nil
+	case "tomorrow", "завтра":
+		return today.AddDate(0, 0, 1), nil
+	case "day after tomorrow", "the day after tomorrow", "послезавтра":
+		return today.AddDate(0, 0, 2), nil
+	}
+
+	if len(words) >= 2 && (words[0] == "in" || words[0] == "через") {
+		rest := words[1:]
+		if rest[0] == "a" || rest[0] == "an" {
+			rest = rest[1:]
+		}
+		n, rest := count(rest)
+		if len(rest) == 1 && n > 0 {
+			switch units[rest[0]] {
+			case unitDay:
+				return today.AddDate(0, 0, n), nil
+			case unitWeek:
+				return today.AddDate(0, 0, 7*n), nil
+			case unitMonth:
+				return today.AddDate(0, n, 0), nil
+			case unitYear:
+				return today.AddDate(n, 0, 0), nil
+			}
+		}
+	}
+
+	if days, ok := weekDays(words); ok && len(days) == 1 {
+		return nextWeekDay(today, days[0], false), nil
+	}
+
+	return time.Time{}, notRecognized(input)
+}
+
+// ParseRepeat переводит фразу вроде «каждые 2 дня», «по понедельникам и
+// средам» или «every 2 weeks on monday» в правило повторения nextdate.
+// Правило может требовать сдвинуть дату начала start (например, на
+// понедельник), поэтому возвращается и новая дата начала.
+func ParseRepeat(input string, start time.Time) (string, time.Time, error) {
+	repeat, start, err := parseRepeat(fields(input), start)
+	if err != nil {
+		return "", start, notRecognized(input)
+	}
+	if _, err := nextdate.Parse(repeat); err != nil {
+		return "", start, err
+	}
+	return repeat, start, nil
+}
+
+func parseRepeat(words []string, start time.Time) (string, time.Time, error) {
+	if len(words) == 0 {
+		return "", start, ErrNotRecognized
+	}
+
+	if len(words) == 1 {
+		switch words[0] {
+		case "daily", "ежедневно":
+			return "d 1", start, nil
+		case "weekly", "еженедельно":
+			return "w " + strconv.Itoa(weekDayNum(start.Weekday())), start, nil
+		case "monthly", "ежемесячно":
+			return "m " + strconv.Itoa(start.Day()), start, nil
+		case "yearly", "annually", "ежегодно":
+			return "y", start, nil
+		case "hourly", "ежечасно":
+			return "h 1", start, nil
+		}
+	}
+
+	switch words[0] {
+	case "every", "each", "каждый", "каждую", "каждые", "каждое", "каждого", "по":
+		words = words[1:]
+	default:
+		return "", start, ErrNotRecognized
+	}
+
+	// «every monday and wednesday», «по понедельникам и средам»
+	if days, ok := weekDays(words); ok {
+		return "w " + joinInts(days), start, nil
+	}
+
+	// «every weekday», «по будням»
+	if len(words) == 1 && (words[0] == "weekday" || words[0] == "будням") {
+		return "b 1", start, nil
+	}
+
+	n, words := count(words)
+	if len(words) == 0 || n < 1 {
+		return "", start, ErrNotRecognized
+	}
+
+	// «every 2 business days», «каждые 3 рабочих дня»
+	if len(words) == 2 && businessWords[words[0]] && units[words[1]] == unitDay {
+		return "b " + strconv.Itoa(n), start, nil
+	}
+
+	unit := units[words[0]]
+	days, hasDays := weekDays(words[1:])
+	if len(words) > 1 && !hasDays {
+		return "", start, ErrNotRecognized
+	}
+	if hasDays && unit != unitWeek {
+		return "", start, ErrNotRecognized
+	}
+
+	switch unit {
+	case unitMinute:
+		return "min " + strconv.Itoa(n), start, nil
+	case unitHour:
+		return "h " + strconv.Itoa(n), start, nil
+	case unitDay:
+		return "d " + strconv.Itoa(n), start, nil
+	case unitWeek:
+		if n == 1 {
+			if !hasDays {
+				days = []int{weekDayNum(start.Weekday())}
+			}
+			return "w " + joinInts(days), start, nil
+		}
+		// недели отсчитываются от даты начала, поэтому сдвигаем её на первый
+		// подходящий день, чтобы повторения начинались с ближайшей недели
+		if !hasDays {
+			days = []int{weekDayNum(start.Weekday())}
+		}
+		first := nextWeekDay(start, days[0], true)
+		for _, day := range days[1:] {
+			if date := nextWeekDay(start, day, true); date.Before(first) {
+				first = date
+			}
+		}
+		return "w " + joinInts(days) + " /" + strconv.Itoa(n), first, nil
+	case unitMonth:
+		if n == 1 {
+			return "m " + strconv.Itoa(start.Day()), start, nil
+		}
+	case unitYear:
+		if n == 1 {
+			return "y", start, nil
+		}
+	}
+
+	return "", start, ErrNotRecognized
+}
+
+func joinInts(nums []int) string {
+	s := make([]string, len(nums))
+	for i, num := range nums {
+		s[i] = strconv.Itoa(num)
+	}
+	return strings.Join(s, ",")
+}
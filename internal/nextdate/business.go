@@ -0,0 +1,204 @@
+package nextdate
+
+import (
+	"strconv"
+	"time"
+)
+
+// BusinessCalendar определяет рабочие дни для правила b и модификаторов
+// +b, -b и !b.
+type BusinessCalendar interface {
+	IsBusinessDay(date time.Time) bool
+}
+
+// isBusinessDay проверяет date по календарю cal; без календаря
+// нерабочие только субботы и воскресенья.
+func isBusinessDay(cal BusinessCalendar, date time.Time) bool {
+	if cal == nil {
+		return date.Weekday() != time.Saturday && date.Weekday() != time.Sunday
+	}
+	return cal.IsBusinessDay(date)
+}
+
+// WithCalendar задаёт производственный календарь правилам b, +b, -b и !b.
+// Другие правила возвращаются без изменений.
+func WithCalendar(rule Rule, cal BusinessCalendar) Rule {
+	switch r := rule.(type) {
+	case BusinessDayRule:
+		r.Calendar = cal
+		return r
+	case ShiftedRule:
+		r.Calendar = cal
+		return r
+	case exceptRule:
+		r.Rule = WithCalendar(r.Rule, cal)
+		return r
+	}
+	return rule
+}
+
+// maxShift ограничивает поиск рабочего дня: длиннее праздников не бывает.
+const maxShift = 60
+
+// BusinessDayRule — «b N»: каждые N рабочих дней.
+type BusinessDayRule struct {
+	Interval int
+	// Calendar — производственный календарь; nil означает, что нерабочие
+	// только субботы и воскресенья.
+	Calendar BusinessCalendar
+}
+
+func (r BusinessDayRule) String() string { return "b " + strconv.Itoa(r.Interval) }
+
+func (r BusinessDayRule) Next(now time.Time, start time.Time) time.Time {
+	return byDays(now, start, func(now time.Time, daysLater time.Time) time.Time {
+		for {
+			for n := r.Interval; n > 0; {
+				daysLater = daysLater.AddDate(0, 0, 1)
+				if isBusinessDay(r.Calendar, daysLater) {
+					n--
+				}
+			}
+			if daysLater.Compare(now) >= 0 {
+				return daysLater
+			}
+		}
+	})
+}
+
+// Shift — модификатор правила для дат, выпадающих на нерабочий день.
+type Shift string
+
+const (
+	ShiftNext Shift = "+b" // перенести на следующий рабочий день
+	ShiftPrev Shift = "-b" // перенести на предыдущий рабочий день
+	ShiftSkip Shift = "!b" // пропустить повторение
+)
+
+// ShiftedRule — правило по дням с модификатором, например «m 15 -b»:
+// 15-го числа, а если это нерабочий день — в предыдущий рабочий.
+// Следующие повторения отсчитываются по исходному правилу от даты
+// до переноса, поэтому перенос не сдвигает расписание.
+type ShiftedRule struct {
+	Rule  Rule
+	Shift Shift
+	// Calendar — производственный календарь, как у BusinessDayRule.
+	Calendar BusinessCalendar
+	// Base — дата повторения по исходному правилу, перенесённого на дату
+	// задачи; нулевое значение — дата задачи сама отсчитывается по правилу.
+	Base time.Time
+}
+
+func (r ShiftedRule) String() string { return r.Rule.String() + " " + string(r.Shift) }
+
+// Next ищет ближайшее повторение, которое после переноса остаётся в
+// будущем; повторения, которые нельзя перенести, пропускаются.
+func (r ShiftedRule) Next(now time.Time, start time.Time) time.Time {
+	next, _, ok := r.next(now, start)
+	if !ok {
+		return start
+	}
+	return next
+}
+
+// base возвращает дату, от которой исходное правило отсчитывается для
+// задачи с датой start: Base, если при переносе она попадает на start,
+// иначе саму start.
+func (r ShiftedRule) base(start time.Time) time.Time {
+	if r.Base.IsZero() {
+		return start
+	}
+	base := time.Date(r.Base.Year(), r.Base.Month(), r.Base.Day(), start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+	if shifted, ok := r.shift(base); ok && shifted.Equal(start) {
+		return base
+	}
+	return start
+}
+
+// next возвращает ближайшее повторение после переноса и его дату по
+// исходному правилу. Перенесённая дата должна быть позже start, а перенос
+// назад не должен уводить её в прошлое относительно now.
+func (r ShiftedRule) next(now time.Time, start time.Time) (time.Time, time.Time, bool) {
+	wall := wallClock(now, start.Location())
+	tomorrow := time.Date(wall.Year(), wall.Month(), wall.Day()+1, 0, 0, 0, 0, start.Location())
+
+	c := Occurrences(r.base(start), r.Rule)
+	next, ok := c.After(now)
+	for i := 0; ok && i < maxShift*10; i++ {
+		shifted, movable := r.shift(next)
+		if movable && shifted.After(start) && (shifted.Equal(next) || !shifted.Before(tomorrow)) {
+			return shifted, next, true
+		}
+		next, ok = c.Next()
+	}
+
+	return start, start, false
+}
+
+// advance возвращает правило для задачи, перенесённой с from на повторение
+// to: запоминает дату to по исходному правилу.
+func (r ShiftedRule) advance(from time.Time, to time.Time) ShiftedRule {
+	c := Occurrences(r.base(from), r.Rule)
+	c.Next() // само повторение from
+	limit := to.AddDate(0, 0, maxShift)
+	for next, ok := c.Next(); ok && !next.After(limit); next, ok = c.Next() {
+		if shifted, movable := r.shift(next); movable && shifted.Equal(to) {
+			r.Base = next
+			return r
+		}
+	}
+	r.Base = time.Time{}
+	return r
+}
+
+// WithShiftBase задаёт правилу с модификатором +b, -b или !b дату
+// повторения по исходному правилу, перенесённого на дату задачи.
+// Другие правила возвращаются без изменений.
+func WithShiftBase(rule Rule, base time.Time) Rule {
+	switch r := rule.(type) {
+	case ShiftedRule:
+		r.Base = base
+		return r
+	case exceptRule:
+		r.Rule = WithShiftBase(r.Rule, base)
+		return r
+	}
+	return rule
+}
+
+// ShiftBase возвращает дату по исходному правилу для повторения start,
+// если она отличается от start; иначе — нулевое время.
+func ShiftBase(rule Rule, start time.Time) time.Time {
+	switch r := rule.(type) {
+	case ShiftedRule:
+		if base := r.base(start); !base.Equal(start) {
+			return base
+		}
+	case exceptRule:
+		return ShiftBase(r.Rule, start)
+	}
+	return time.Time{}
+}
+
+func (r ShiftedRule) shift(date time.Time) (time.Time, bool) {
+	if isBusinessDay(r.Calendar, date) {
+		return date, true
+	}
+
+	step := 1
+	switch r.Shift {
+	case ShiftSkip:
+		return date, false
+	case ShiftPrev:
+		step = -1
+	}
+
+	for i := 0; i < maxShift; i++ {
+		date = date.AddDate(0, 0, step)
+		if isBusinessDay(r.Calendar, date) {
+			return date, true
+		}
+	}
+
+	return date, false
+}
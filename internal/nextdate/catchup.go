@@ -0,0 +1,110 @@
+package nextdate
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/PhilippElizarov/go_final_project/internal/model"
+)
+
+// CatchUp — как выполнение просроченной повторяющейся задачи выбирает её
+// следующую дату.
+type CatchUp string
+
+const (
+	// SkipToFuture — ближайшее повторение после сегодняшнего дня; все
+	// пропущенные повторения пропадают. Используется по умолчанию.
+	SkipToFuture CatchUp = "skip-to-future"
+	// NextAfterPrevious — повторение, следующее за датой задачи, даже если
+	// оно тоже прошло: каждое пропущенное повторение выполняется по очереди.
+	NextAfterPrevious CatchUp = "next-after-previous"
+	// FromCompletion — правило отсчитывается заново от момента выполнения:
+	// «d 7» назначает задачу через 7 дней после того, как её выполнили.
+	FromCompletion CatchUp = "fixed-interval-from-completion"
+)
+
+// ParseCatchUp проверяет режим; пустая строка означает SkipToFuture.
+func ParseCatchUp(s string) (CatchUp, error) {
+	switch c := CatchUp(s); c {
+	case "":
+		return SkipToFuture, nil
+	case SkipToFuture, NextAfterPrevious, FromCompletion:
+		return c, nil
+	}
+	return "", fmt.Errorf("неизвестный режим выполнения пропущенных повторений %q", s)
+}
+
+// Done возвращает следующую дату задачи, начинающейся в start и выполненной
+// в момент doneAt, и правило с учётом пройденных повторений (COUNT в RRULE);
+// false означает, что повторений больше нет. Для плавающих правил (d+ N)
+// режим всегда FromCompletion. В режиме FromCompletion следующая дата может
+// оказаться раньше start, если задачу выполнили заранее.
+func (c CatchUp) Done(doneAt time.Time, start time.Time, rule Rule) (time.Time, Rule, bool) {
+	// плавающие правила всегда отсчитываются от выполнения
+	if Floating(rule) {
+		c = FromCompletion
+	}
+
+	from, now := start, doneAt
+	switch c {
+	case NextAfterPrevious:
+		now = start
+	case FromCompletion:
+		from = completion(doneAt, start, rule)
+		now = from
+	}
+
+	cursor := Occurrences(from, rule)
+	next, ok := cursor.After(now)
+	if !ok {
+		return start, rule, false
+	}
+	return next, cursor.Rule(), true
+}
+
+// NextDate — как функция NextDate, но следующая дата выбирается в режиме c,
+// а рабочие дни определяются по календарю cal.
+func (c CatchUp) NextDate(now time.Time, date string, repeat string, cal BusinessCalendar) (string, error) {
+	start, err := time.Parse(model.TimeTemplate, date)
+	if err != nil {
+		return "", err
+	}
+
+	rule, err := Parse(repeat)
+	if err != nil {
+		return "", err
+	}
+
+	next, _, ok := c.Done(now, start, WithCalendar(rule, cal))
+	if !ok {
+		return "", ErrNoOccurrences
+	}
+
+	return next.Format(model.TimeTemplate), nil
+}
+
+// completion переносит start на момент выполнения doneAt: у правил по дням
+// меняется только дата, время суток start сохраняется.
+func completion(doneAt time.Time, start time.Time, rule Rule) time.Time {
+	doneAt = wallClock(doneAt, start.Location())
+	if Timed(rule) {
+		return doneAt.Truncate(time.Minute)
+	}
+	y, m, d := doneAt.Date()
+	return time.Date(y, m, d, start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+}
+
+// Timed сообщает, что правило повторяется в течение дня (h, min и RRULE
+// с частотой или BYHOUR/BYMINUTE внутри дня), и его повторения нельзя
+// представить одними датами.
+func Timed(rule Rule) bool {
+	switch r := rule.(type) {
+	case HourlyRule, MinutelyRule:
+		return true
+	case RRule:
+		return r.timed()
+	case exceptRule:
+		return Timed(r.Rule)
+	}
+	return false
+}
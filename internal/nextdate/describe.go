@@ -0,0 +1,415 @@
+package nextdate
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	weekDaysRu = []string{"", "понедельникам", "вторникам", "средам", "четвергам", "пятницам", "субботам", "воскресеньям"}
+	weekDaysEn = []string{"", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday", "Sunday"}
+	monthsInRu = []string{"", "январе", "феврале", "марте", "апреле", "мае", "июне", "июле", "августе", "сентябре", "октябре", "ноябре", "декабре"}
+	monthsRu   = []string{"", "января", "февраля", "марта", "апреля", "мая", "июня", "июля", "августа", "сентября", "октября", "ноября", "декабря"}
+	// дни недели в винительном падеже и род, с которым согласуется порядковое числительное
+	weekDaysAccRu    = []string{"", "понедельник", "вторник", "среду", "четверг", "пятницу", "субботу", "воскресенье"}
+	weekDaysGenderRu = []int{0, 0, 0, 1, 0, 1, 1, 2}
+	ordinalsRu       = map[int][3]string{
+		1: {"первый", "первую", "первое"}, 2: {"второй", "вторую", "второе"},
+		3: {"третий", "третью", "третье"}, 4: {"четвёртый", "четвёртую", "четвёртое"},
+		5: {"пятый", "пятую", "пятое"}, -1: {"последний", "последнюю", "последнее"},
+	}
+	ordinalWordsEn = map[int]string{1: "first", 2: "second", 3: "third", 4: "fourth", 5: "fifth", -1: "last"}
+	monthsEn       = []string{"", "January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+)
+
+// Describe возвращает описание правила на языке locale: "en" — английский,
+// иначе русский.
+func Describe(rule Rule, locale string) string {
+	if strings.HasPrefix(strings.ToLower(locale), "en") {
+		return describeEn(rule)
+	}
+	return describeRu(rule)
+}
+
+var (
+	shiftsRu = map[Shift]string{
+		ShiftNext: "с переносом на следующий рабочий день",
+		ShiftPrev: "с переносом на предыдущий рабочий день",
+		ShiftSkip: "кроме выходных и праздников",
+	}
+	shiftsEn = map[Shift]string{
+		ShiftNext: "moved to the next business day",
+		ShiftPrev: "moved to the previous business day",
+		ShiftSkip: "skipping weekends and holidays",
+	}
+)
+
+func describeRu(rule Rule) string {
+	switch r := rule.(type) {
+	case ShiftedRule:
+		return describeRu(r.Rule) + ", " + shiftsRu[r.Shift]
+	case BusinessDayRule:
+		return everyRu(r.Interval, "каждый", "рабочий день", "рабочих дня", "рабочих дней")
+	case FloatingRule:
+		units := map[string][3]string{
+			"d": {"день", "дня", "дней"},
+			"w": {"неделю", "недели", "недель"},
+			"m": {"месяц", "месяца", "месяцев"},
+		}
+		unit := units[r.Unit]
+		return afterRu(r.Interval, unit[0], unit[1], unit[2]) + " после выполнения"
+	case DailyRule:
+		return everyRu(r.Interval, "каждый", "день", "дня", "дней")
+	case HourlyRule:
+		return everyRu(r.Interval, "каждый", "час", "часа", "часов")
+	case MinutelyRule:
+		return everyRu(r.Interval, "каждую", "минуту", "минуты", "минут")
+	case YearlyRule:
+		return "ежегодно"
+	case RRule:
+		units := map[Frequency][4]string{
+			Minutely: {"каждую", "минуту", "минуты", "минут"},
+			Hourly:   {"каждый", "час", "часа", "часов"},
+			Daily:    {"каждый", "день", "дня", "дней"},
+			Weekly:   {"каждую", "неделю", "недели", "недель"},
+			Monthly:  {"каждый", "месяц", "месяца", "месяцев"},
+			Yearly:   {"каждый", "год", "года", "лет"},
+		}
+		unit := units[r.Freq]
+		text := everyRu(r.Interval, unit[0], unit[1], unit[2], unit[3])
+		if r.readable() {
+			text += r.selectorsRu()
+		} else if selectors := r.selectors(); len(selectors) > 0 {
+			text += " (" + strings.Join(selectors, ";") + ")"
+		}
+		if n := r.Count; n > 0 {
+			times := " раз"
+			if n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14) {
+				times = " раза"
+			}
+			text += ", всего " + strconv.Itoa(n) + times
+		}
+		if !r.Until.IsZero() {
+			text += ", до " + r.Until.Format("02.01.2006")
+		}
+		return text
+	case WeeklyRule:
+		text := "по " + joinRu(pick(weekDaysRu, sortedDays(r.Days)))
+		if r.Interval > 1 {
+			text += " " + everyRu(r.Interval, "каждую", "неделю", "недели", "недель")
+		}
+		return text
+	case MonthlyRule:
+		var parts []string
+		var days []string
+		for _, day := range sortedDays(r.Days) {
+			switch day {
+			case -1:
+				parts = append(parts, "в последний день")
+			case -2:
+				parts = append(parts, "в предпоследний день")
+			default:
+				days = append(days, strconv.Itoa(day)+"-го")
+			}
+		}
+		if len(days) > 0 {
+			parts = append([]string{joinRu(days) + " числа"}, parts...)
+		}
+		for _, weekDay := range r.WeekDays {
+			parts = append(parts, ordinalWeekDayRu(weekDay))
+		}
+		if len(r.Months) == 0 {
+			return joinRu(parts) + " каждого месяца"
+		}
+		return joinRu(parts) + " " + joinRu(pick(monthsRu, sortedDays(r.Months)))
+	}
+	return rule.String()
+}
+
+func describeEn(rule Rule) string {
+	switch r := rule.(type) {
+	case ShiftedRule:
+		return describeEn(r.Rule) + ", " + shiftsEn[r.Shift]
+	case BusinessDayRule:
+		return everyEn(r.Interval, "business day")
+	case FloatingRule:
+		unit := map[string]string{"d": "day", "w": "week", "m": "month"}[r.Unit]
+		if r.Interval == 1 {
+			return "a " + unit + " after completion"
+		}
+		return strconv.Itoa(r.Interval) + " " + unit + "s after completion"
+	case DailyRule:
+		return everyEn(r.Interval, "day")
+	case HourlyRule:
+		return everyEn(r.Interval, "hour")
+	case MinutelyRule:
+		return everyEn(r.Interval, "minute")
+	case YearlyRule:
+		return "every year"
+	case RRule:
+		units := map[Frequency]string{Minutely: "minute", Hourly: "hour", Daily: "day", Weekly: "week", Monthly: "month", Yearly: "year"}
+		text := everyEn(r.Interval, units[r.Freq])
+		if r.readable() {
+			text += r.selectorsEn()
+		} else if selectors := r.selectors(); len(selectors) > 0 {
+			text += " (" + strings.Join(selectors, ";") + ")"
+		}
+		if r.Count == 1 {
+			text += ", once"
+		} else if r.Count > 1 {
+			text += ", " + strconv.Itoa(r.Count) + " times"
+		}
+		if !r.Until.IsZero() {
+			text += ", until " + r.Until.Format("2006-01-02")
+		}
+		return text
+	case WeeklyRule:
+		if r.Interval > 1 {
+			return everyEn(r.Interval, "week") + " on " + joinEn(pick(weekDaysEn, sortedDays(r.Days)))
+		}
+		return "every " + joinEn(pick(weekDaysEn, sortedDays(r.Days)))
+	case MonthlyRule:
+		var parts []string
+		for _, day := range sortedDays(r.Days) {
+			switch day {
+			case -1:
+				parts = append(parts, "the last day")
+			case -2:
+				parts = append(parts, "the second to last day")
+			default:
+				parts = append(parts, "the "+ordinalEn(day))
+			}
+		}
+		for _, weekDay := range r.WeekDays {
+			parts = append(parts, "the "+ordinalWordsEn[weekDay.N]+" "+weekDaysEn[weekDay.Day])
+		}
+		if len(r.Months) == 0 {
+			return "on " + joinEn(parts) + " of every month"
+		}
+		return "on " + joinEn(parts) + " of " + joinEn(pick(monthsEn, sortedDays(r.Months)))
+	}
+	return rule.String()
+}
+
+// maxDescribedTimes ограничивает число моментов дня, перечисляемых словами.
+const maxDescribedTimes = 6
+
+// readable сообщает, что части BYxxx правила RRULE описываются словами:
+// дни недели, дни месяца, месяцы и время суток. BYSETPOS, BYWEEKNO и
+// BYYEARDAY, а также дни, отсчитанные от начала года, выводятся как есть.
+func (r RRule) readable() bool {
+	if len(r.BySetPos) > 0 || len(r.ByWeekNo) > 0 || len(r.ByYearDay) > 0 {
+		return false
+	}
+	if (len(r.ByHour) > 0) != (len(r.ByMinute) > 0) || len(r.ByHour)*len(r.ByMinute) > maxDescribedTimes {
+		return false
+	}
+	// без BYMONTH ежегодные дни отсчитываются от начала года
+	if r.Freq == Yearly && len(r.ByMonth) == 0 && (len(r.ByMonthDay) > 0 || len(r.ByDay) > 0) {
+		return false
+	}
+	for _, day := range r.ByMonthDay {
+		if day < -2 {
+			return false
+		}
+	}
+	for _, day := range r.ByDay {
+		if _, ok := ordinalWordsEn[day.N]; day.N != 0 && (!ok || r.Freq != Monthly && r.Freq != Yearly) {
+			return false
+		}
+	}
+	return true
+}
+
+// times перечисляет моменты дня из BYHOUR и BYMINUTE.
+func (r RRule) times() []string {
+	var times []string
+	for _, hour := range sortedDays(r.ByHour) {
+		for _, minute := range sortedDays(r.ByMinute) {
+			times = append(times, fmt.Sprintf("%d:%02d", hour, minute))
+		}
+	}
+	return times
+}
+
+func (r RRule) selectorsRu() string {
+	var parts []string
+	var days []string
+	for _, day := range sortedDays(r.ByMonthDay) {
+		switch day {
+		case -1:
+			parts = append(parts, "в последний день")
+		case -2:
+			parts = append(parts, "в предпоследний день")
+		default:
+			days = append(days, strconv.Itoa(day)+"-го")
+		}
+	}
+	if len(days) > 0 {
+		parts = append([]string{joinRu(days) + " числа"}, parts...)
+	}
+
+	var weekDays []int
+	for _, day := range r.ByDay {
+		if day.N == 0 {
+			weekDays = append(weekDays, day.Day)
+		} else {
+			parts = append(parts, ordinalWeekDayRu(day))
+		}
+	}
+	if len(weekDays) > 0 {
+		parts = append(parts, "по "+joinRu(pick(weekDaysRu, sortedDays(weekDays))))
+	}
+
+	var text string
+	if len(parts) > 0 {
+		text += " " + joinRu(parts)
+	}
+	if len(r.ByMonth) > 0 {
+		text += " в " + joinRu(pick(monthsInRu, sortedDays(r.ByMonth)))
+	}
+	if times := r.times(); len(times) > 0 {
+		text += " в " + joinRu(times)
+	}
+	return text
+}
+
+func (r RRule) selectorsEn() string {
+	var parts []string
+	for _, day := range sortedDays(r.ByMonthDay) {
+		switch day {
+		case -1:
+			parts = append(parts, "the last day")
+		case -2:
+			parts = append(parts, "the second to last day")
+		default:
+			parts = append(parts, "the "+ordinalEn(day))
+		}
+	}
+
+	var weekDays []int
+	for _, day := range r.ByDay {
+		if day.N == 0 {
+			weekDays = append(weekDays, day.Day)
+		} else {
+			parts = append(parts, "the "+ordinalWordsEn[day.N]+" "+weekDaysEn[day.Day])
+		}
+	}
+	parts = append(parts, pick(weekDaysEn, sortedDays(weekDays))...)
+
+	var text string
+	if len(parts) > 0 {
+		text += " on " + joinEn(parts)
+	}
+	if len(r.ByMonth) > 0 {
+		text += " in " + joinEn(pick(monthsEn, sortedDays(r.ByMonth)))
+	}
+	if times := r.times(); len(times) > 0 {
+		text += " at " + joinEn(times)
+	}
+	return text
+}
+
+// ordinalWeekDayRu согласует числительное с днём недели: «в первую среду», «во второй вторник».
+func ordinalWeekDayRu(d OrdinalWeekDay) string {
+	ordinal := ordinalsRu[d.N][weekDaysGenderRu[d.Day]]
+	prep := "в "
+	if strings.HasPrefix(ordinal, "вт") {
+		prep = "во "
+	}
+	return prep + ordinal + " " + weekDaysAccRu[d.Day]
+}
+
+// everyRu склоняет «каждые N дней»: каждый день, каждые 2 дня, каждый 21 день.
+func everyRu(n int, each string, one string, few string, many string) string {
+	if n == 1 {
+		return each + " " + one
+	}
+	switch {
+	case n%10 == 1 && n%100 != 11:
+		return each + " " + strconv.Itoa(n) + " " + one
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		return "каждые " + strconv.Itoa(n) + " " + few
+	}
+	return "каждые " + strconv.Itoa(n) + " " + many
+}
+
+// afterRu склоняет «через N дней»: через день, через 3 дня, через 21 день.
+func afterRu(n int, one string, few string, many string) string {
+	if n == 1 {
+		return "через " + one
+	}
+	switch {
+	case n%10 == 1 && n%100 != 11:
+		return "через " + strconv.Itoa(n) + " " + one
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		return "через " + strconv.Itoa(n) + " " + few
+	}
+	return "через " + strconv.Itoa(n) + " " + many
+}
+
+func everyEn(n int, unit string) string {
+	if n == 1 {
+		return "every " + unit
+	}
+	return "every " + strconv.Itoa(n) + " " + unit + "s"
+}
+
+func ordinalEn(n int) string {
+	suffix := "th"
+	if n%100 < 11 || n%100 > 13 {
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return strconv.Itoa(n) + suffix
+}
+
+// sortedDays упорядочивает дни по возрастанию, а -1 и -2 ставит в конец.
+func sortedDays(days []int) []int {
+	sorted := slices.Clone(days)
+	slices.SortFunc(sorted, func(a, b int) int {
+		if (a < 0) != (b < 0) {
+			if a < 0 {
+				return 1
+			}
+			return -1
+		}
+		if a < 0 {
+			return b - a
+		}
+		return a - b
+	})
+	return slices.Compact(sorted)
+}
+
+func pick(names []string, nums []int) []string {
+	res := make([]string, len(nums))
+	for i, num := range nums {
+		res[i] = names[num]
+	}
+	return res
+}
+
+func joinRu(items []string) string {
+	return joinList(items, " и ")
+}
+
+func joinEn(items []string) string {
+	return joinList(items, " and ")
+}
+
+// joinList перечисляет через запятую, а последний элемент присоединяет через and.
+func joinList(items []string, and string) string {
+	if len(items) <= 1 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + and + items[len(items)-1]
+}
@@ -0,0 +1,72 @@
+package nextdate
+
+import (
+	"time"
+
+	"github.com/PhilippElizarov/go_final_project/internal/model"
+)
+
+// maxSkipped ограничивает число подряд пропущенных дат.
+const maxSkipped = 1000
+
+type exceptRule struct {
+	Rule
+	skip map[string]bool
+}
+
+// WithExceptions возвращает правило, которое пропускает повторения,
+// выпадающие на даты skip (в формате model.TimeTemplate).
+func WithExceptions(rule Rule, skip []string) Rule {
+	if len(skip) == 0 {
+		return rule
+	}
+	r := exceptRule{Rule: rule, skip: make(map[string]bool, len(skip))}
+	for _, date := range skip {
+		r.skip[date] = true
+	}
+	return r
+}
+
+// Next пропускает повторения на датах из skip: все повторения такой даты
+// пропускаются сразу, поэтому у правил h и min перебор не идёт по каждому.
+func (r exceptRule) Next(now time.Time, start time.Time) time.Time {
+	c := Occurrences(start, r.Rule)
+	next, ok := c.After(now)
+	for skipped := 0; ok && r.skip[next.Format(model.TimeTemplate)]; skipped++ {
+		if skipped == maxSkipped {
+			return start
+		}
+		next, ok = c.After(endOfDay(next))
+	}
+	if !ok {
+		// повторения закончились или пропущены все оставшиеся
+		return start
+	}
+	return next
+}
+
+// IsOccurrence сообщает, что на дату date приходится повторение задачи,
+// начинающейся в start; проверяются даты не раньше start.
+func IsOccurrence(rule Rule, start time.Time, date string) bool {
+	day, err := time.ParseInLocation(model.TimeTemplate, date, start.Location())
+	if err != nil {
+		return false
+	}
+	if first := start.Format(model.TimeTemplate); date <= first {
+		return date == first
+	}
+	// правила по дням могут вернуть и сам предыдущий день, поэтому до date
+	// остаётся не больше одного шага
+	c := Occurrences(start, rule)
+	next, ok := c.After(day.Add(-time.Second))
+	for ok && next.Format(model.TimeTemplate) < date {
+		next, ok = c.Next()
+	}
+	return ok && next.Format(model.TimeTemplate) == date
+}
+
+// endOfDay возвращает последнюю секунду дня t.
+func endOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 23, 59, 59, 0, t.Location())
+}
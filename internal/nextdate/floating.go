@@ -0,0 +1,55 @@
+package nextdate
+
+import (
+	"strconv"
+	"time"
+)
+
+// FloatingRule — «d+ 3», «w+ 2», «m+ 1»: через Interval дней, недель или
+// месяцев после выполнения задачи. Пока задача не выполнена, повторения
+// отсчитываются от её даты, как у d N.
+type FloatingRule struct {
+	// Unit — «d», «w» или «m».
+	Unit     string
+	Interval int
+}
+
+func (r FloatingRule) String() string { return r.Unit + "+ " + strconv.Itoa(r.Interval) }
+
+func (r FloatingRule) Next(now time.Time, start time.Time) time.Time {
+	return byDays(now, start, func(now time.Time, day time.Time) time.Time {
+		for i := 1; ; i++ {
+			if next := r.after(day, i); next.Compare(now) >= 0 {
+				return next
+			}
+		}
+	})
+}
+
+// after возвращает дату через n интервалов после day; у m+ день месяца,
+// которого нет в месяце, заменяется последним днём.
+func (r FloatingRule) after(day time.Time, n int) time.Time {
+	switch r.Unit {
+	case "w":
+		return day.AddDate(0, 0, 7*r.Interval*n)
+	case "m":
+		y, m, d := day.Date()
+		first := time.Date(y, m+time.Month(r.Interval*n), 1, 0, 0, 0, 0, day.Location())
+		if last := first.AddDate(0, 1, -1).Day(); d > last {
+			d = last
+		}
+		return first.AddDate(0, 0, d-1)
+	}
+	return day.AddDate(0, 0, r.Interval*n)
+}
+
+// Floating сообщает, что правило отсчитывается от выполнения задачи.
+func Floating(rule Rule) bool {
+	switch r := rule.(type) {
+	case FloatingRule:
+		return true
+	case exceptRule:
+		return Floating(r.Rule)
+	}
+	return false
+}
@@ -4,59 +4,103 @@ import (
 	"errors"
 	"slices"
 	"sort"
-	"strconv"
-	"strings"
 	"time"
-
-	"github.com/PhilippElizarov/go_final_project/internal/model"
 )
 
+// ErrNoOccurrences — у правила не осталось повторений (COUNT или UNTIL в RRULE).
+var ErrNoOccurrences = errors.New("повторений больше нет")
+
 type ByDate []time.Time
 
 func (a ByDate) Len() int           { return len(a) }
 func (a ByDate) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a ByDate) Less(i, j int) bool { return a[i].Before(a[j]) }
 
+// NextDate возвращает дату, на которую переходит задача с датой date,
+// если выполнить её в момент now: ближайшее повторение после now, а для
+// плавающих правил (d+ N) — повторение, отсчитанное от now. Нерабочими днями
+// считаются только субботы и воскресенья.
 func NextDate(now time.Time, date string, repeat string) (string, error) {
+	return SkipToFuture.NextDate(now, date, repeat, nil)
+}
 
-	if repeat == "" {
-		return "", errors.New("не указано правило повторения")
+// Next работает с полными отметками времени: правила h и min отсчитывают
+// часы и минуты от start и возвращают первое повторение строго после now,
+// а правила по дням сравнивают только даты и сохраняют время суток start.
+// now сравнивается по показаниям часов в своём часовом поясе.
+func Next(now time.Time, start time.Time, repeat string) (time.Time, error) {
+	rule, err := Parse(repeat)
+	if err != nil {
+		return time.Time{}, err
 	}
 
-	date_, err := time.Parse(model.TimeTemplate, date)
-	if err != nil {
-		return "", err
+	next, ok := Occurrences(start, rule).After(now)
+	if !ok {
+		return time.Time{}, ErrNoOccurrences
 	}
 
-	s := strings.Split(repeat, " ")
+	return next, nil
+}
 
-	daysLater := date_
+// wallClock переносит показания часов now в пояс loc.
+func wallClock(now time.Time, loc *time.Location) time.Time {
+	return time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), now.Second(), now.Nanosecond(), loc)
+}
 
-	switch repeat[0] {
-	case 'd':
-		if len(s) != 2 {
-			return "", errors.New("не указан интервал в днях")
-		}
+func (r HourlyRule) Next(now time.Time, start time.Time) time.Time {
+	return byInterval(now, start, int64(r.Interval)*int64(time.Hour/time.Second))
+}
 
-		//проверяем корректность введенных дней
-		num, err := strconv.Atoi(s[1])
-		if err != nil {
-			return "", err
-		}
+func (r MinutelyRule) Next(now time.Time, start time.Time) time.Time {
+	return byInterval(now, start, int64(r.Interval)*int64(time.Minute/time.Second))
+}
+
+// byInterval отсчитывает интервал в секундах от start; считаем в секундах,
+// так как разница с давней датой не помещается в time.Duration
+func byInterval(now time.Time, start time.Time, interval int64) time.Time {
+	loc := start.Location()
+	now = wallClock(now, loc)
 
-		if !(num > 0 && num <= 400) {
-			return "", errors.New("неверный диапазон дней")
+	next := start.Unix() + interval
+	if next <= now.Unix() {
+		next += (now.Unix() - next) / interval * interval
+		if next <= now.Unix() {
+			next += interval
 		}
+	}
+
+	return time.Unix(next, 0).In(loc)
+}
 
-		//вычисляем новую дату
+// byDays вызывает nextDay для дат без времени суток: для правил по дням
+// время не учитывается, а в результате сохраняется время start.
+func byDays(now time.Time, start time.Time, nextDay func(now time.Time, daysLater time.Time) time.Time) time.Time {
+	loc := start.Location()
+	now = wallClock(now, loc)
+
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	next := nextDay(today, day)
+
+	return time.Date(next.Year(), next.Month(), next.Day(), start.Hour(), start.Minute(), start.Second(), 0, loc)
+}
+
+func (r DailyRule) Next(now time.Time, start time.Time) time.Time {
+	return byDays(now, start, func(now time.Time, daysLater time.Time) time.Time {
 		for {
-			daysLater = daysLater.AddDate(0, 0, num)
+			daysLater = daysLater.AddDate(0, 0, r.Interval)
 			res := daysLater.Compare(now)
 			if res == 0 || res == 1 {
 				break
 			}
 		}
-	case 'y':
+		return daysLater
+	})
+}
+
+func (r YearlyRule) Next(now time.Time, start time.Time) time.Time {
+	return byDays(now, start, func(now time.Time, daysLater time.Time) time.Time {
 		for {
 			daysLater = daysLater.AddDate(1, 0, 0)
 			res := daysLater.Compare(now)
@@ -64,127 +108,118 @@ func NextDate(now time.Time, date string, repeat string) (string, error) {
 				break
 			}
 		}
-	case 'w':
-		if len(s) != 2 {
-			return "", errors.New("не указаны дни недели")
-		}
-
-		weekDays := strings.Split(s[1], ",")
-
-		var weekDaysNums []int
-
-		//проверяем корректность введенных дней недели
-		for _, day := range weekDays {
-			num, err := strconv.Atoi(day)
-			if err != nil {
-				return "", err
-			}
-
-			if !(num >= 1 && num <= 7) {
-				return "", errors.New("неверный диапазон дней недели")
-			}
-			weekDaysNums = append(weekDaysNums, num)
-		}
+		return daysLater
+	})
+}
 
-		var dayOfWeek = map[string]int{
-			"Monday":    1,
-			"Tuesday":   2,
-			"Wednesday": 3,
-			"Thursday":  4,
-			"Friday":    5,
-			"Saturday":  6,
-			"Sunday":    7,
-		}
+func (r WeeklyRule) Next(now time.Time, start time.Time) time.Time {
+	var dayOfWeek = map[string]int{
+		"Monday":    1,
+		"Tuesday":   2,
+		"Wednesday": 3,
+		"Thursday":  4,
+		"Friday":    5,
+		"Saturday":  6,
+		"Sunday":    7,
+	}
 
-		//вычисляем новую дату
+	return byDays(now, start, func(now time.Time, daysLater time.Time) time.Time {
+		anchor := daysLater
 		for {
 			daysLater = daysLater.AddDate(0, 0, 1)
 			res := daysLater.Compare(now)
 			weekDayNum := dayOfWeek[daysLater.Weekday().String()]
-			if res == 1 && slices.Contains(weekDaysNums, weekDayNum) {
+			if res == 1 && slices.Contains(r.Days, weekDayNum) && r.onWeek(anchor, daysLater) {
 				break
 			}
 		}
-	case 'm':
-		if len(s) < 2 || len(s) > 3 {
-			return "", errors.New("некорректные параметры повторения")
-		}
+		return daysLater
+	})
+}
 
-		days := strings.Split(s[1], ",")
+// onWeek сообщает, что неделя даты day повторяется при отсчёте от недели
+// даты anchor; недели начинаются с понедельника.
+func (r WeeklyRule) onWeek(anchor time.Time, day time.Time) bool {
+	if r.Interval <= 1 {
+		return true
+	}
+	return weeksBetween(anchor, day)%r.Interval == 0
+}
 
-		var daysNums []int
-		//проверяем корректность введенных дней
-		for _, day := range days {
-			num, err := strconv.Atoi(day)
-			if err != nil {
-				return "", err
-			}
+func weeksBetween(from time.Time, to time.Time) int {
+	monday := func(t time.Time) time.Time {
+		// считаем в UTC, чтобы переход на летнее время не сбивал число дней
+		t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return t.AddDate(0, 0, -(int(t.Weekday())+6)%7)
+	}
+	return int(monday(to).Sub(monday(from)).Hours()) / 24 / 7
+}
 
-			if !(num >= 1 && num <= 31) && num != -1 && num != -2 {
-				return "", errors.New("неверный диапазон дней")
-			}
-			daysNums = append(daysNums, num)
-		}
-		var months []string
-		if len(s) > 2 {
-			months = strings.Split(s[2], ",")
-		}
+// in возвращает дату дня недели в месяце; false, если такого дня нет
+// (например, пятого понедельника).
+func (d OrdinalWeekDay) in(year int, month time.Month, loc *time.Location) (time.Time, bool) {
+	wd := func(t time.Time) int { return (int(t.Weekday())+6)%7 + 1 }
 
-		var monthsNums []int
-		//проверяем корректность введенных месяцев
-		for _, month := range months {
-			num, err := strconv.Atoi(month)
-			if err != nil {
-				return "", err
-			}
+	if d.N == -1 {
+		last := time.Date(year, month+1, 0, 0, 0, 0, 0, loc)
+		return last.AddDate(0, 0, -(wd(last)-d.Day+7)%7), true
+	}
 
-			if !(num >= 1 && num <= 12) {
-				return "", errors.New("неверный диапазон месяцев")
-			}
-			monthsNums = append(monthsNums, num)
-		}
+	first := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	date := first.AddDate(0, 0, (d.Day-wd(first)+7)%7+7*(d.N-1))
+	return date, date.Month() == month
+}
 
-		if len(monthsNums) == 0 {
-			monthsNums = []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+// gregorianCycle — через столько месяцев (400 лет) григорианский календарь
+// повторяется: если дня правила нет за этот срок, его нет никогда.
+const gregorianCycle = 400 * 12
+
+func (r MonthlyRule) Next(now time.Time, start time.Time) time.Time {
+	return byDays(now, start, func(now time.Time, daysLater time.Time) time.Time {
+		after := daysLater
+		if now.After(after) {
+			after = now
 		}
 
-		var dateNxt time.Time
-		var dates []time.Time
-		year, _, _ := daysLater.Date()
-
-		for _, day := range daysNums {
-			for _, month := range monthsNums {
-				for y := year; y < year+5; y++ {
-					if day == -1 || day == -2 {
-						firstOfMonth := time.Date(y, time.Month(month), 1, 0, 0, 0, 0, daysLater.Location())
-						dateNxt = time.Date(y, time.Month(month), firstOfMonth.AddDate(0, 1, day).Day(), daysLater.Hour(),
-							daysLater.Minute(), daysLater.Second(), daysLater.Nanosecond(), daysLater.Location())
-					} else {
-						dateNxt = time.Date(y, time.Month(month), day, daysLater.Hour(),
-							daysLater.Minute(), daysLater.Second(), daysLater.Nanosecond(), daysLater.Location())
-						if dateNxt.Month() != time.Month(month) || dateNxt.Day() != day {
-							continue
-						}
-					}
-					cmp := dateNxt.Compare(daysLater)
-					if cmp == 1 {
-						dates = append(dates, dateNxt)
-					}
-				}
+		year, month, _ := after.Date()
+		for i := 0; i <= gregorianCycle; i++ {
+			first := time.Date(year, month+time.Month(i), 1, 0, 0, 0, 0, after.Location())
+			if len(r.Months) > 0 && !slices.Contains(r.Months, int(first.Month())) {
+				continue
+			}
+			if next, ok := r.first(first, after); ok {
+				return next
 			}
 		}
+		return daysLater
+	})
+}
 
-		sort.Sort(ByDate(dates))
-		for _, d := range dates {
-			cmp := d.Compare(now)
-			if cmp == 1 {
-				daysLater = d
-				break
-			}
+// first возвращает самый ранний день правила в месяце, начинающемся
+// с first, который позже after.
+func (r MonthlyRule) first(first time.Time, after time.Time) (time.Time, bool) {
+	var dates []time.Time
+	for _, day := range r.Days {
+		if day == -1 || day == -2 {
+			dates = append(dates, first.AddDate(0, 1, day))
+			continue
+		}
+		date := first.AddDate(0, 0, day-1)
+		if date.Month() == first.Month() {
+			dates = append(dates, date)
+		}
+	}
+	for _, weekDay := range r.WeekDays {
+		if date, ok := weekDay.in(first.Year(), first.Month(), first.Location()); ok {
+			dates = append(dates, date)
 		}
-	default:
-		return "", errors.New("неподдерживаемый формат повторения")
 	}
 
-	return daysLater.Format(model.TimeTemplate), nil
+	sort.Sort(ByDate(dates))
+	for _, date := range dates {
+		if date.After(after) {
+			return date, true
+		}
+	}
+	return time.Time{}, false
 }
@@ -0,0 +1,92 @@
+package nextdate
+
+import "time"
+
+// Cursor перебирает повторения задачи по одному: каждое следующее
+// повторение вычисляется только по запросу, поэтому число повторений ничем
+// не ограничено. Каждое следующее повторение правила m и RRULE ищется не
+// дальше 400 лет вперёд, а у RRULE — и не дальше maxRRulePeriods периодов.
+// У RRULE с COUNT курсор учитывает пройденные повторения.
+type Cursor struct {
+	rule    Rule
+	current time.Time
+	started bool
+	done    bool
+}
+
+// Occurrences возвращает курсор по повторениям задачи, начинающейся
+// в start; первым повторением считается сама start.
+func Occurrences(start time.Time, rule Rule) *Cursor {
+	return &Cursor{rule: rule, current: start}
+}
+
+// Next возвращает следующее повторение; false — повторений больше нет.
+func (c *Cursor) Next() (time.Time, bool) {
+	if c.done {
+		return time.Time{}, false
+	}
+	if !c.started {
+		c.started = true
+		return c.current, true
+	}
+	return c.move(c.rule.Next(c.current, c.current))
+}
+
+// After пропускает повторения до момента now и возвращает первое из
+// оставшихся так, как его выбирает Rule.Next: для правил по дням — после
+// сегодняшней даты now, для правил h и min — после самого момента.
+func (c *Cursor) After(now time.Time) (time.Time, bool) {
+	if c.done {
+		return time.Time{}, false
+	}
+	c.started = true
+	return c.move(c.rule.Next(now, c.current))
+}
+
+// Rule возвращает правило с учётом пройденных курсором повторений.
+func (c *Cursor) Rule() Rule {
+	return c.rule
+}
+
+func (c *Cursor) move(next time.Time) (time.Time, bool) {
+	// дата не сдвинулась: больше повторений нет
+	if !next.After(c.current) {
+		c.done = true
+		return time.Time{}, false
+	}
+	c.rule, _ = Advance(c.rule, c.current, next)
+	c.current = next
+	return next, true
+}
+
+// Upcoming возвращает до count ближайших повторений задачи, начинающейся
+// в start: саму start, если она ещё не прошла, и следующие по правилу rule,
+// как если бы задачу отмечали выполненной в день каждого повторения.
+// Повторения позже until не возвращаются, если until задан.
+func Upcoming(now time.Time, start time.Time, rule Rule, count int, until time.Time) []time.Time {
+	var dates []time.Time
+
+	c := Occurrences(start, rule)
+	current, ok := c.Next()
+	if !sameDayOrLater(start, now) {
+		current, ok = c.After(now)
+	}
+
+	for ok && len(dates) < count {
+		if !until.IsZero() && current.After(until) {
+			break
+		}
+		dates = append(dates, current)
+		current, ok = c.Next()
+	}
+
+	return dates
+}
+
+// sameDayOrLater сообщает, что дата start не раньше сегодняшней даты now,
+// то есть задача ещё не просрочена.
+func sameDayOrLater(start time.Time, now time.Time) bool {
+	now = wallClock(now, start.Location())
+	y, m, d := now.Date()
+	return !start.Before(time.Date(y, m, d, 0, 0, 0, 0, start.Location()))
+}
@@ -0,0 +1,806 @@
+package nextdate
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency — частота повторения RRULE (FREQ).
+type Frequency int
+
+const (
+	Minutely Frequency = iota
+	Hourly
+	Daily
+	Weekly
+	Monthly
+	Yearly
+)
+
+var frequencies = []string{"MINUTELY", "HOURLY", "DAILY", "WEEKLY", "MONTHLY", "YEARLY"}
+
+func (f Frequency) String() string { return frequencies[f] }
+
+// MaxRRuleInterval — наибольший INTERVAL в правиле RRULE.
+const MaxRRuleInterval = 10000
+
+// MaxRRuleCount — наибольший COUNT в правиле RRULE: повторения с COUNT
+// отсчитываются от даты начала, и большое число делает перебор долгим.
+const MaxRRuleCount = 1000
+
+// maxRRulePeriods — сколько периодов перебирается в поисках повторения:
+// ограничивает время перебора, даже если горизонт ещё не достигнут.
+const maxRRulePeriods = 1000000
+
+// rruleHorizon — сколько лет после даты начала и текущей даты ищутся
+// повторения RRULE: за 400 лет календарь повторяется полностью, поэтому
+// если повторений нет за это время, их нет вообще.
+const rruleHorizon = 400
+
+// RRule — правило повторения в формате RFC 5545 (RRULE), например
+// «FREQ=MONTHLY;BYDAY=MO,TU;BYSETPOS=-1;COUNT=10». Дата начала задачи
+// играет роль DTSTART и считается первым повторением. Время считается
+// по показаниям часов, без секунд: BYSECOND допускается только равным 0.
+type RRule struct {
+	Freq     Frequency
+	Interval int
+	// Count — число повторений, считая дату начала; 0 — без ограничения.
+	Count int
+	// Until — последний допустимый момент повторения; нулевое значение — без
+	// ограничения. Если UntilDate, ограничение действует до конца дня Until.
+	Until     time.Time
+	UntilDate bool
+	// UntilUTC означает, что Until задан в UTC и переводится в пояс задачи.
+	UntilUTC   bool
+	ByMonth    []int
+	ByWeekNo   []int
+	ByYearDay  []int
+	ByMonthDay []int
+	// ByDay — дни недели (1 — понедельник), N — порядковый номер в месяце
+	// или году, 0 — каждый такой день.
+	ByDay     []OrdinalWeekDay
+	ByHour    []int
+	ByMinute  []int
+	BySetPos  []int
+	WeekStart int
+}
+
+// IsRRule сообщает, что правило записано в формате RRULE.
+func IsRRule(repeat string) bool {
+	upper := strings.ToUpper(repeat)
+	return strings.HasPrefix(upper, "FREQ=") || strings.HasPrefix(upper, "RRULE:")
+}
+
+func (r RRule) String() string {
+	parts := []string{"FREQ=" + r.Freq.String()}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		until := r.Until.Format("20060102T150405")
+		switch {
+		case r.UntilDate:
+			until = r.Until.Format("20060102")
+		case r.UntilUTC:
+			until += "Z"
+		}
+		parts = append(parts, "UNTIL="+until)
+	}
+	parts = append(parts, r.selectors()...)
+	if r.WeekStart != 1 {
+		parts = append(parts, "WKST="+rruleWeekDays[r.WeekStart])
+	}
+	return strings.Join(parts, ";")
+}
+
+// selectors возвращает части BYxxx в каноническом порядке.
+func (r RRule) selectors() []string {
+	var parts []string
+	add := func(name string, nums []int) {
+		if len(nums) > 0 {
+			parts = append(parts, name+"="+joinInts(nums))
+		}
+	}
+	add("BYMONTH", r.ByMonth)
+	add("BYWEEKNO", r.ByWeekNo)
+	add("BYYEARDAY", r.ByYearDay)
+	add("BYMONTHDAY", r.ByMonthDay)
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			if day.N != 0 {
+				days[i] = strconv.Itoa(day.N)
+			}
+			days[i] += rruleWeekDays[day.Day]
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	add("BYHOUR", r.ByHour)
+	add("BYMINUTE", r.ByMinute)
+	add("BYSETPOS", r.BySetPos)
+	return parts
+}
+
+var rruleWeekDays = []string{"", "MO", "TU", "WE", "TH", "FR", "SA", "SU"}
+
+// parseRRule разбирает правило RRULE с необязательным префиксом «RRULE:».
+func parseRRule(repeat string) (Rule, error) {
+	p := &parser{repeat: repeat}
+	rule := RRule{Interval: 1, WeekStart: 1}
+
+	off := 0
+	if strings.HasPrefix(strings.ToUpper(repeat), "RRULE:") {
+		off = len("RRULE:")
+	}
+
+	seen := make(map[string]bool)
+	var (
+		until token
+		byDay token
+	)
+	for _, part := range strings.Split(repeat[off:], ";") {
+		tok := token{part, off}
+		off += len(part) + 1
+
+		name, value, found := strings.Cut(part, "=")
+		name = strings.ToUpper(name)
+		value = strings.ToUpper(value)
+		if !found || value == "" {
+			return nil, p.errorAt(tok.off, tok.text, "ожидается часть правила вида ИМЯ=ЗНАЧЕНИЕ")
+		}
+		if seen[name] {
+			return nil, p.errorAt(tok.off, tok.text, "часть правила повторяется")
+		}
+		seen[name] = true
+
+		val := token{value, tok.off + len(name) + 1}
+		var err error
+		switch name {
+		case "FREQ":
+			freq := slices.Index(frequencies, value)
+			if freq < 0 {
+				if value == "SECONDLY" {
+					return nil, p.errorAt(val.off, value, "повторение по секундам не поддерживается")
+				}
+				return nil, p.errorAt(val.off, value, "неизвестная частота повторения")
+			}
+			rule.Freq = Frequency(freq)
+		case "INTERVAL":
+			rule.Interval, err = p.number(val, between(1, MaxRRuleInterval), "неверный диапазон интервала")
+		case "COUNT":
+			rule.Count, err = p.number(val, between(1, MaxRRuleCount), "число повторений должно быть от 1 до "+strconv.Itoa(MaxRRuleCount))
+		case "UNTIL":
+			until = val
+			rule.Until, rule.UntilDate, rule.UntilUTC, err = p.until(val)
+		case "BYSECOND":
+			_, err = p.list(val, between(0, 0), "секунды не поддерживаются")
+		case "BYMINUTE":
+			rule.ByMinute, err = p.list(val, between(0, 59), "неверный диапазон минут")
+		case "BYHOUR":
+			rule.ByHour, err = p.list(val, between(0, 23), "неверный диапазон часов")
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = p.list(val, nonZero(31), "неверный диапазон дней месяца")
+		case "BYYEARDAY":
+			rule.ByYearDay, err = p.list(val, nonZero(366), "неверный диапазон дней года")
+		case "BYWEEKNO":
+			rule.ByWeekNo, err = p.list(val, nonZero(53), "неверный диапазон номеров недель")
+		case "BYMONTH":
+			rule.ByMonth, err = p.list(val, between(1, 12), "неверный диапазон месяцев")
+		case "BYSETPOS":
+			rule.BySetPos, err = p.list(val, nonZero(366), "неверный диапазон позиций")
+		case "BYDAY":
+			byDay = val
+			rule.ByDay, err = p.byDay(val)
+		case "WKST":
+			rule.WeekStart = slices.Index(rruleWeekDays, value)
+			if rule.WeekStart < 1 {
+				return nil, p.errorAt(val.off, value, "неизвестный день недели")
+			}
+		default:
+			return nil, p.errorAt(tok.off, name, "неизвестная часть правила")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	end := len(repeat)
+	switch {
+	case !seen["FREQ"]:
+		return nil, p.errorAt(end, "", "не указана частота FREQ")
+	case seen["COUNT"] && seen["UNTIL"]:
+		return nil, p.errorAt(until.off, until.text, "COUNT и UNTIL нельзя указывать вместе")
+	case seen["BYWEEKNO"] && rule.Freq != Yearly:
+		return nil, p.errorAt(end, "", "BYWEEKNO допускается только при FREQ=YEARLY")
+	case seen["BYYEARDAY"] && (rule.Freq == Daily || rule.Freq == Weekly || rule.Freq == Monthly):
+		return nil, p.errorAt(end, "", "BYYEARDAY не допускается при FREQ="+rule.Freq.String())
+	case seen["BYMONTHDAY"] && rule.Freq == Weekly:
+		return nil, p.errorAt(end, "", "BYMONTHDAY не допускается при FREQ=WEEKLY")
+	case seen["BYSETPOS"] && !slices.ContainsFunc(byParts, func(name string) bool { return seen[name] }):
+		return nil, p.errorAt(end, "", "BYSETPOS требует другой части BYxxx")
+	}
+
+	// порядковые номера дней недели имеют смысл только внутри месяца или года
+	for _, day := range rule.ByDay {
+		if day.N == 0 {
+			continue
+		}
+		limit := 53
+		if rule.Freq == Monthly || rule.Freq == Yearly && len(rule.ByMonth) > 0 {
+			limit = 5
+		}
+		if rule.Freq != Monthly && rule.Freq != Yearly || len(rule.ByWeekNo) > 0 || day.N > limit || day.N < -limit {
+			return nil, p.errorAt(byDay.off, byDay.text, "порядковый номер дня недели не допускается")
+		}
+	}
+
+	return rule, nil
+}
+
+func nonZero(max int) func(int) bool {
+	return func(num int) bool { return num != 0 && num >= -max && num <= max }
+}
+
+// byParts — части, которые выбирают повторения внутри периода; BYSETPOS
+// выбирает среди них.
+var byParts = []string{"BYSECOND", "BYMINUTE", "BYHOUR", "BYDAY", "BYMONTHDAY", "BYYEARDAY", "BYWEEKNO", "BYMONTH"}
+
+// until разбирает UNTIL: дату 20060102 или дату и время 20060102T150405,
+// возможно в UTC (с суффиксом Z).
+func (p *parser) until(tok token) (time.Time, bool, bool, error) {
+	if date, err := time.Parse("20060102", tok.text); err == nil {
+		return date, true, false, nil
+	}
+	text, utc := strings.CutSuffix(tok.text, "Z")
+	date, err := time.Parse("20060102T150405", text)
+	if err != nil {
+		return time.Time{}, false, false, p.errorAt(tok.off, tok.text, "неверная дата UNTIL")
+	}
+	return date, false, utc, nil
+}
+
+// byDay разбирает BYDAY вида MO,-1FR,2TU.
+func (p *parser) byDay(tok token) ([]OrdinalWeekDay, error) {
+	var days []OrdinalWeekDay
+	off := tok.off
+	for _, item := range strings.Split(tok.text, ",") {
+		if len(item) < 2 {
+			return nil, p.errorAt(off, item, "неизвестный день недели")
+		}
+		day := OrdinalWeekDay{Day: slices.Index(rruleWeekDays, item[len(item)-2:])}
+		if day.Day < 1 {
+			return nil, p.errorAt(off, item, "неизвестный день недели")
+		}
+		if n := strings.TrimPrefix(item[:len(item)-2], "+"); n != "" {
+			var err error
+			day.N, err = p.number(token{n, off}, nonZero(53), "неверный порядковый номер дня недели")
+			if err != nil {
+				return nil, err
+			}
+		}
+		days = append(days, day)
+		off += len(item) + 1
+	}
+	return days, nil
+}
+
+// timed сообщает, что правило повторяется чаще раза в день, поэтому
+// повторения сравниваются с now с точностью до минуты, а не по датам.
+func (r RRule) timed() bool {
+	return r.Freq == Minutely || r.Freq == Hourly || len(r.ByHour) > 0 || len(r.ByMinute) > 0
+}
+
+// Next возвращает первое повторение после start, которое позже now:
+// для правил чаще раза в день — позже момента now, иначе — позже
+// сегодняшней даты. Если повторения закончились (COUNT или UNTIL),
+// возвращается start.
+func (r RRule) Next(now time.Time, start time.Time) time.Time {
+	loc := start.Location()
+	now = wallClock(now, loc)
+
+	after := floating(now)
+	if !r.timed() {
+		y, m, d := now.Date()
+		after = time.Date(y, m, d, 23, 59, 59, 0, time.UTC)
+	}
+
+	it := r.iterator(floating(start), after, loc)
+	for {
+		next, ok := it.next()
+		if !ok {
+			return start
+		}
+		if next.After(after) {
+			return time.Date(next.Year(), next.Month(), next.Day(), next.Hour(), next.Minute(), next.Second(), 0, loc)
+		}
+	}
+}
+
+// Advance возвращает правило для задачи, перенесённой с повторения from на
+// более позднее повторение to, и сообщает, изменилось ли оно: у RRULE с COUNT
+// из числа повторений вычитаются пройденные. Правило с переносом на рабочий
+// день запоминает дату to по исходному правилу (см. ShiftBase), но его
+// запись не меняется. Остальные правила от даты начала не зависят.
+func Advance(rule Rule, from time.Time, to time.Time) (Rule, bool) {
+	if e, ok := rule.(exceptRule); ok {
+		advanced, changed := Advance(e.Rule, from, to)
+		e.Rule = advanced
+		return e, changed
+	}
+
+	if r, ok := rule.(ShiftedRule); ok && to.After(from) {
+		return r.advance(from, to), false
+	}
+
+	r, ok := rule.(RRule)
+	if !ok || r.Count == 0 || !to.After(from) {
+		return rule, false
+	}
+
+	target := floating(wallClock(to, from.Location()))
+	it := r.iterator(floating(from), target, from.Location())
+	// seen уже учитывает повторения, пропущенные без перебора
+	passed := it.seen - 1
+	for {
+		next, ok := it.next()
+		if !ok || next.After(target) {
+			break
+		}
+		passed = it.seen - 1
+	}
+	r.Count -= passed
+	if r.Count < 1 {
+		r.Count = 1
+	}
+	return r, true
+}
+
+// floating переводит показания часов t в UTC: повторения RRULE считаются
+// по показаниям часов, и переход на летнее время не должен сбивать интервалы.
+func floating(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
+// rruleIterator перебирает повторения правила после dtstart по периодам
+// длиной FREQ с шагом INTERVAL.
+type rruleIterator struct {
+	rule    RRule
+	dtstart time.Time
+	until   time.Time
+	// horizon — после этого момента повторения больше не ищутся
+	horizon time.Time
+	// period — начало текущего периода
+	period time.Time
+	// periods — сколько периодов перебрано
+	periods int
+	// seen — сколько повторений пройдено, считая dtstart
+	seen    int
+	pending []time.Time
+}
+
+// iterator создаёт перебор повторений после dtstart. Периоды до skipTo
+// пропускаются без перебора, если COUNT не задан или число повторений
+// в периоде постоянно (тогда пропущенные повторения считаются умножением).
+// UNTIL в UTC переводится в пояс задачи loc.
+func (r RRule) iterator(dtstart time.Time, skipTo time.Time, loc *time.Location) *rruleIterator {
+	it := &rruleIterator{rule: r, dtstart: dtstart, seen: 1}
+
+	switch {
+	case r.Until.IsZero():
+	case r.UntilDate:
+		it.until = r.Until.Add(24*time.Hour - time.Second)
+	case r.UntilUTC:
+		it.until = floating(r.Until.In(loc))
+	default:
+		it.until = r.Until
+	}
+
+	latest := dtstart
+	if skipTo.After(latest) {
+		latest = skipTo
+	}
+	it.horizon = latest.AddDate(rruleHorizon, 0, 0)
+	if !r.reachable(dtstart) {
+		it.horizon = time.Time{}
+	}
+
+	it.period = r.periodStart(dtstart)
+	perPeriod, constant := r.perPeriod()
+	if (r.Count == 0 || constant) && skipTo.After(dtstart) {
+		// число периодов до skipTo кратно INTERVAL и берётся с запасом в один шаг
+		steps := r.periodsBetween(it.period, r.periodStart(skipTo))/r.Interval - 1
+		if steps > 0 {
+			if r.Count > 0 {
+				for _, t := range r.expand(it.period, dtstart) {
+					if t.After(dtstart) {
+						it.seen++
+					}
+				}
+				it.seen += (steps - 1) * perPeriod
+			}
+			it.period = r.addPeriods(it.period, steps*r.Interval)
+		}
+	}
+
+	return it
+}
+
+func (it *rruleIterator) next() (time.Time, bool) {
+	r := it.rule
+	for len(it.pending) == 0 {
+		if it.period.After(it.horizon) || it.periods >= maxRRulePeriods {
+			return time.Time{}, false
+		}
+		it.periods++
+		for _, t := range r.expand(it.period, it.dtstart) {
+			if t.After(it.dtstart) {
+				it.pending = append(it.pending, t)
+			}
+		}
+		it.period = r.nextPeriod(it.period)
+	}
+
+	next := it.pending[0]
+	it.pending = it.pending[1:]
+
+	if !it.until.IsZero() && next.After(it.until) {
+		it.horizon = time.Time{}
+		it.pending = nil
+		return time.Time{}, false
+	}
+	it.seen++
+	if r.Count > 0 && it.seen > r.Count {
+		it.horizon = time.Time{}
+		it.pending = nil
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+// reachable сообщает, что периоды с шагом INTERVAL от dtstart попадают
+// хотя бы в один час из BYHOUR и минуту из BYMINUTE: например, у
+// FREQ=MINUTELY;INTERVAL=2;BYMINUTE=1 с началом в 00:00 все периоды
+// начинаются в чётные минуты, и повторений нет.
+func (r RRule) reachable(dtstart time.Time) bool {
+	var unit, start int
+	switch r.Freq {
+	case Minutely:
+		unit, start = 24*60, dtstart.Hour()*60+dtstart.Minute()
+	case Hourly:
+		unit, start = 24, dtstart.Hour()
+	default:
+		return true
+	}
+	// начало периода в сутках пробегает значения, сравнимые с началом
+	// dtstart по модулю НОД(INTERVAL, длина суток)
+	step := gcd(r.Interval, unit)
+	for value := 0; value < unit; value++ {
+		h, m := value, -1
+		if r.Freq == Minutely {
+			h, m = value/60, value%60
+		}
+		if len(r.ByHour) > 0 && !slices.Contains(r.ByHour, h) ||
+			m >= 0 && len(r.ByMinute) > 0 && !slices.Contains(r.ByMinute, m) {
+			continue
+		}
+		if (value-start)%step == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// perPeriod возвращает число повторений в каждом периоде, если оно от
+// периода не зависит: частей, которые выбирают дни месяца или года, нет,
+// а BYDAY есть только у еженедельного правила.
+func (r RRule) perPeriod() (int, bool) {
+	if len(r.ByMonth) > 0 || len(r.ByWeekNo) > 0 || len(r.ByYearDay) > 0 || len(r.ByMonthDay) > 0 || len(r.BySetPos) > 0 {
+		return 0, false
+	}
+	hours, minutes := max(distinct(r.ByHour), 1), max(distinct(r.ByMinute), 1)
+	switch r.Freq {
+	case Minutely:
+		if len(r.ByHour) > 0 || len(r.ByMinute) > 0 || len(r.ByDay) > 0 {
+			return 0, false
+		}
+		return 1, true
+	case Hourly:
+		if len(r.ByHour) > 0 || len(r.ByDay) > 0 {
+			return 0, false
+		}
+		return minutes, true
+	case Daily:
+		if len(r.ByDay) > 0 {
+			return 0, false
+		}
+		return hours * minutes, true
+	case Weekly:
+		days := make(map[int]bool)
+		for _, d := range r.ByDay {
+			days[d.Day] = true
+		}
+		return max(len(days), 1) * hours * minutes, true
+	}
+	return 0, false
+}
+
+func distinct(nums []int) int {
+	seen := make(map[int]bool, len(nums))
+	for _, num := range nums {
+		seen[num] = true
+	}
+	return len(seen)
+}
+
+// periodStart возвращает начало периода, в который попадает t.
+func (r RRule) periodStart(t time.Time) time.Time {
+	y, m, d := t.Date()
+	switch r.Freq {
+	case Minutely:
+		return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, time.UTC)
+	case Hourly:
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, time.UTC)
+	case Daily:
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	case Weekly:
+		day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -((weekDayNum(day)-r.WeekStart)+7)%7)
+	case Monthly:
+		return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(y, time.January, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func (r RRule) addPeriods(period time.Time, n int) time.Time {
+	switch r.Freq {
+	case Minutely:
+		return period.Add(time.Duration(n) * time.Minute)
+	case Hourly:
+		return period.Add(time.Duration(n) * time.Hour)
+	case Daily:
+		return period.AddDate(0, 0, n)
+	case Weekly:
+		return period.AddDate(0, 0, 7*n)
+	case Monthly:
+		return period.AddDate(0, n, 0)
+	}
+	return period.AddDate(n, 0, 0)
+}
+
+func (r RRule) periodsBetween(from time.Time, to time.Time) int {
+	switch r.Freq {
+	case Minutely:
+		return int(to.Sub(from) / time.Minute)
+	case Hourly:
+		return int(to.Sub(from) / time.Hour)
+	case Daily:
+		return int(to.Sub(from) / (24 * time.Hour))
+	case Weekly:
+		return int(to.Sub(from) / (7 * 24 * time.Hour))
+	case Monthly:
+		return (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+	}
+	return to.Year() - from.Year()
+}
+
+// nextPeriod переходит к следующему периоду с шагом INTERVAL. У правил чаще
+// раза в день периоды внутри неподходящего дня пропускаются целиком, а у
+// ежеминутного — и внутри часа не из BYHOUR.
+func (r RRule) nextPeriod(period time.Time) time.Time {
+	next := r.addPeriods(period, r.Interval)
+	if r.Freq != Minutely && r.Freq != Hourly {
+		return next
+	}
+
+	var skipTo time.Time
+	switch {
+	case !r.dayMatches(period):
+		skipTo = time.Date(period.Year(), period.Month(), period.Day()+1, 0, 0, 0, 0, time.UTC)
+	case r.Freq == Minutely && len(r.ByHour) > 0 && !slices.Contains(r.ByHour, period.Hour()):
+		skipTo = time.Date(period.Year(), period.Month(), period.Day(), period.Hour()+1, 0, 0, 0, time.UTC)
+	default:
+		return next
+	}
+	if next.Before(skipTo) {
+		unit := time.Minute
+		if r.Freq == Hourly {
+			unit = time.Hour
+		}
+		step := time.Duration(r.Interval) * unit
+		next = period.Add((skipTo.Sub(period) + step - 1) / step * step)
+	}
+	return next
+}
+
+// expand возвращает повторения в периоде по порядку, с учётом BYSETPOS.
+func (r RRule) expand(period time.Time, dtstart time.Time) []time.Time {
+	var days []time.Time
+	switch r.Freq {
+	case Yearly:
+		for d := period; d.Year() == period.Year(); d = d.AddDate(0, 0, 1) {
+			days = append(days, d)
+		}
+	case Monthly:
+		for d := period; d.Month() == period.Month(); d = d.AddDate(0, 0, 1) {
+			days = append(days, d)
+		}
+	case Weekly:
+		for i := 0; i < 7; i++ {
+			days = append(days, period.AddDate(0, 0, i))
+		}
+	default:
+		days = []time.Time{time.Date(period.Year(), period.Month(), period.Day(), 0, 0, 0, 0, time.UTC)}
+	}
+
+	hours, minutes := r.ByHour, r.ByMinute
+	if len(hours) == 0 {
+		hours = []int{dtstart.Hour()}
+	}
+	if len(minutes) == 0 {
+		minutes = []int{dtstart.Minute()}
+	}
+	switch r.Freq {
+	case Hourly:
+		if len(r.ByHour) > 0 && !slices.Contains(r.ByHour, period.Hour()) {
+			return nil
+		}
+		hours = []int{period.Hour()}
+	case Minutely:
+		if len(r.ByHour) > 0 && !slices.Contains(r.ByHour, period.Hour()) ||
+			len(r.ByMinute) > 0 && !slices.Contains(r.ByMinute, period.Minute()) {
+			return nil
+		}
+		hours, minutes = []int{period.Hour()}, []int{period.Minute()}
+	}
+	hours, minutes = slices.Clone(hours), slices.Clone(minutes)
+	slices.Sort(hours)
+	slices.Sort(minutes)
+	hours, minutes = slices.Compact(hours), slices.Compact(minutes)
+
+	var set []time.Time
+	for _, day := range days {
+		if !r.dayMatches(day) || !r.dayDefaults(day, dtstart) {
+			continue
+		}
+		for _, h := range hours {
+			for _, m := range minutes {
+				set = append(set, time.Date(day.Year(), day.Month(), day.Day(), h, m, dtstart.Second(), 0, time.UTC))
+			}
+		}
+	}
+
+	if len(r.BySetPos) == 0 {
+		return set
+	}
+
+	var selected []time.Time
+	for _, pos := range r.BySetPos {
+		i := pos - 1
+		if pos < 0 {
+			i = len(set) + pos
+		}
+		if i >= 0 && i < len(set) {
+			selected = append(selected, set[i])
+		}
+	}
+	slices.SortFunc(selected, func(a, b time.Time) int { return a.Compare(b) })
+	return slices.CompactFunc(selected, func(a, b time.Time) bool { return a.Equal(b) })
+}
+
+// dayMatches проверяет день по частям BYMONTH, BYWEEKNO, BYYEARDAY,
+// BYMONTHDAY и BYDAY.
+func (r RRule) dayMatches(day time.Time) bool {
+	if len(r.ByMonth) > 0 && !slices.Contains(r.ByMonth, int(day.Month())) {
+		return false
+	}
+	if len(r.ByWeekNo) > 0 {
+		week, weeks := r.weekNo(day)
+		if !slices.Contains(r.ByWeekNo, week) && !slices.Contains(r.ByWeekNo, week-weeks-1) {
+			return false
+		}
+	}
+	if len(r.ByYearDay) > 0 {
+		yearDay := day.YearDay()
+		daysInYear := time.Date(day.Year(), time.December, 31, 0, 0, 0, 0, time.UTC).YearDay()
+		if !slices.Contains(r.ByYearDay, yearDay) && !slices.Contains(r.ByYearDay, yearDay-daysInYear-1) {
+			return false
+		}
+	}
+	if len(r.ByMonthDay) > 0 {
+		daysInMonth := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		if !slices.Contains(r.ByMonthDay, day.Day()) && !slices.Contains(r.ByMonthDay, day.Day()-daysInMonth-1) {
+			return false
+		}
+	}
+	if len(r.ByDay) > 0 && !slices.ContainsFunc(r.ByDay, func(d OrdinalWeekDay) bool { return r.weekDayMatches(d, day) }) {
+		return false
+	}
+	return true
+}
+
+// dayDefaults подставляет недостающие части из даты начала: без BYDAY,
+// BYMONTHDAY, BYYEARDAY и BYWEEKNO ежемесячное правило повторяется в день
+// месяца даты начала, ежегодное — ещё и в её месяце, еженедельное — в её
+// день недели.
+func (r RRule) dayDefaults(day time.Time, dtstart time.Time) bool {
+	if len(r.ByDay) > 0 || len(r.ByMonthDay) > 0 || len(r.ByYearDay) > 0 || len(r.ByWeekNo) > 0 {
+		return true
+	}
+	switch r.Freq {
+	case Weekly:
+		return day.Weekday() == dtstart.Weekday()
+	case Monthly:
+		return day.Day() == dtstart.Day()
+	case Yearly:
+		return day.Day() == dtstart.Day() && (len(r.ByMonth) > 0 || day.Month() == dtstart.Month())
+	}
+	return true
+}
+
+// weekDayMatches проверяет день по элементу BYDAY; порядковый номер
+// считается внутри месяца, а у ежегодного правила без BYMONTH — внутри года.
+func (r RRule) weekDayMatches(d OrdinalWeekDay, day time.Time) bool {
+	if weekDayNum(day) != d.Day {
+		return false
+	}
+	if d.N == 0 {
+		return true
+	}
+
+	var first, last time.Time
+	if r.Freq == Yearly && len(r.ByMonth) == 0 {
+		first = time.Date(day.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+		last = time.Date(day.Year(), time.December, 31, 0, 0, 0, 0, time.UTC)
+	} else {
+		first = time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+		last = time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC)
+	}
+
+	if d.N > 0 {
+		return (day.YearDay()-first.YearDay())/7+1 == d.N
+	}
+	return (last.YearDay()-day.YearDay())/7+1 == -d.N
+}
+
+// weekNo возвращает номер недели дня и число недель в его году по RFC 5545:
+// первая неделя года — та, в которой не меньше четырёх дней этого года,
+// недели начинаются с WKST.
+func (r RRule) weekNo(day time.Time) (int, int) {
+	year := day.Year()
+	switch {
+	case !day.Before(r.firstWeek(year + 1)):
+		year++
+	case day.Before(r.firstWeek(year)):
+		year--
+	}
+	first := r.firstWeek(year)
+	week := int(day.Sub(first)/(7*24*time.Hour)) + 1
+	weeks := int(r.firstWeek(year+1).Sub(first) / (7 * 24 * time.Hour))
+	return week, weeks
+}
+
+func (r RRule) firstWeek(year int) time.Time {
+	jan1 := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	start := jan1.AddDate(0, 0, -((weekDayNum(jan1)-r.WeekStart)+7)%7)
+	// в неделе меньше четырёх дней нового года: она относится к прошлому году
+	if jan1.Sub(start) > 3*24*time.Hour {
+		start = start.AddDate(0, 0, 7)
+	}
+	return start
+}
+
+func weekDayNum(day time.Time) int {
+	return (int(day.Weekday())+6)%7 + 1
+}
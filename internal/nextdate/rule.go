@@ -0,0 +1,324 @@
+package nextdate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxWeekInterval — наибольший интервал в неделях для правила w.
+const MaxWeekInterval = 52
+
+// Rule — разобранное правило повторения.
+type Rule interface {
+	// Next возвращает ближайшее повторение задачи, начинающейся в start,
+	// относительно момента now; подробности — в описании функции Next.
+	Next(now time.Time, start time.Time) time.Time
+	// String возвращает правило в каноническом виде, который снова разбирается Parse.
+	String() string
+}
+
+// DailyRule — «d N»: каждые N дней.
+type DailyRule struct {
+	Interval int
+}
+
+// WeeklyRule — «w 1,3»: по указанным дням недели (1 — понедельник, 7 — воскресенье);
+// «w 1,4 /2» — раз в Interval недель, считая от недели, на которую приходится
+// дата начала задачи.
+type WeeklyRule struct {
+	Days     []int
+	Interval int
+}
+
+// MonthlyRule — «m 1,-1 2,8»: по дням месяца (-1 и -2 — последний и
+// предпоследний день) в указанных месяцах или в любом месяце, если их нет.
+// Вместо дня можно указать день недели с порядковым номером: «m 1:1» — первый
+// понедельник, «m -1:5 2,8» — последняя пятница февраля и августа.
+type MonthlyRule struct {
+	Days     []int
+	WeekDays []OrdinalWeekDay
+	Months   []int
+}
+
+// OrdinalWeekDay — N-й день недели Day в месяце; N от 1 до 5 или -1 (последний).
+type OrdinalWeekDay struct {
+	N   int
+	Day int
+}
+
+func (d OrdinalWeekDay) String() string {
+	return strconv.Itoa(d.N) + ":" + strconv.Itoa(d.Day)
+}
+
+// YearlyRule — «y»: ежегодно в день начала задачи.
+type YearlyRule struct{}
+
+// HourlyRule — «h N»: каждые N часов.
+type HourlyRule struct {
+	Interval int
+}
+
+// MinutelyRule — «min N»: каждые N минут.
+type MinutelyRule struct {
+	Interval int
+}
+
+func (r DailyRule) String() string    { return "d " + strconv.Itoa(r.Interval) }
+func (r YearlyRule) String() string   { return "y" }
+func (r HourlyRule) String() string   { return "h " + strconv.Itoa(r.Interval) }
+func (r MinutelyRule) String() string { return "min " + strconv.Itoa(r.Interval) }
+
+func (r WeeklyRule) String() string {
+	if r.Interval > 1 {
+		return "w " + joinInts(r.Days) + " /" + strconv.Itoa(r.Interval)
+	}
+	return "w " + joinInts(r.Days)
+}
+
+func (r MonthlyRule) String() string {
+	days := make([]string, 0, len(r.Days)+len(r.WeekDays))
+	for _, day := range r.Days {
+		days = append(days, strconv.Itoa(day))
+	}
+	for _, day := range r.WeekDays {
+		days = append(days, day.String())
+	}
+	if len(r.Months) == 0 {
+		return "m " + strings.Join(days, ",")
+	}
+	return "m " + strings.Join(days, ",") + " " + joinInts(r.Months)
+}
+
+func joinInts(nums []int) string {
+	s := make([]string, len(nums))
+	for i, num := range nums {
+		s[i] = strconv.Itoa(num)
+	}
+	return strings.Join(s, ",")
+}
+
+// ParseError описывает ошибку в правиле повторения: Pos — номер символа
+// (с нуля), с которого начинается ошибочный фрагмент Token.
+type ParseError struct {
+	Pos   int
+	Token string
+	Msg   string
+}
+
+func (e *ParseError) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("%s (позиция %d)", e.Msg, e.Pos)
+	}
+	return fmt.Sprintf("%s: %q (позиция %d)", e.Msg, e.Token, e.Pos)
+}
+
+// token — часть правила вместе с её смещением в байтах от начала строки.
+type token struct {
+	text string
+	off  int
+}
+
+type parser struct {
+	repeat string
+	tokens []token
+}
+
+func (p *parser) errorAt(off int, text string, msg string) error {
+	return &ParseError{Pos: utf8.RuneCountInString(p.repeat[:off]), Token: text, Msg: msg}
+}
+
+// expect проверяет число параметров правила; min и max считают и само имя правила.
+func (p *parser) expect(min, max int, missing string) error {
+	if len(p.tokens) < min {
+		return p.errorAt(len(p.repeat), "", missing)
+	}
+	if len(p.tokens) > max {
+		extra := p.tokens[max]
+		return p.errorAt(extra.off, extra.text, "лишний параметр")
+	}
+	return nil
+}
+
+// number разбирает число из токена и проверяет, что оно допустимо.
+func (p *parser) number(tok token, valid func(int) bool, msg string) (int, error) {
+	num, err := strconv.Atoi(tok.text)
+	if err != nil {
+		return 0, p.errorAt(tok.off, tok.text, "ожидается число")
+	}
+	if !valid(num) {
+		return 0, p.errorAt(tok.off, tok.text, msg)
+	}
+	return num, nil
+}
+
+// list разбирает список чисел через запятую.
+func (p *parser) list(tok token, valid func(int) bool, msg string) ([]int, error) {
+	var nums []int
+	off := tok.off
+	for _, item := range strings.Split(tok.text, ",") {
+		num, err := p.number(token{item, off}, valid, msg)
+		if err != nil {
+			return nil, err
+		}
+		nums = append(nums, num)
+		off += len(item) + 1
+	}
+	return nums, nil
+}
+
+func between(min, max int) func(int) bool {
+	return func(num int) bool { return num >= min && num <= max }
+}
+
+// Parse разбирает правило повторения: собственное (d 7, w 1,3, m 15 …) или
+// RRULE по RFC 5545, которое начинается с FREQ= или RRULE:. Ошибки
+// возвращаются как *ParseError.
+func Parse(repeat string) (Rule, error) {
+	p := &parser{repeat: repeat}
+	if repeat == "" {
+		return nil, &ParseError{Msg: "не указано правило повторения"}
+	}
+	if IsRRule(repeat) {
+		return parseRRule(repeat)
+	}
+
+	off := 0
+	for _, text := range strings.Split(repeat, " ") {
+		p.tokens = append(p.tokens, token{text, off})
+		off += len(text) + 1
+	}
+
+	// модификатор переноса на рабочий день стоит последним
+	if last := p.tokens[len(p.tokens)-1]; len(p.tokens) > 1 {
+		switch shift := Shift(last.text); shift {
+		case ShiftNext, ShiftPrev, ShiftSkip:
+			rule, err := Parse(repeat[:last.off-1])
+			if err != nil {
+				return nil, err
+			}
+			switch rule.(type) {
+			case DailyRule, WeeklyRule, MonthlyRule, YearlyRule:
+				return ShiftedRule{Rule: rule, Shift: shift}, nil
+			}
+			return nil, p.errorAt(last.off, last.text, "модификатор не применим к этому правилу")
+		}
+	}
+
+	name := p.tokens[0]
+	switch name.text {
+	case "d":
+		if err := p.expect(2, 2, "не указан интервал в днях"); err != nil {
+			return nil, err
+		}
+		num, err := p.number(p.tokens[1], between(1, 400), "неверный диапазон дней")
+		if err != nil {
+			return nil, err
+		}
+		return DailyRule{Interval: num}, nil
+	case "d+", "w+", "m+":
+		if err := p.expect(2, 2, "не указан интервал"); err != nil {
+			return nil, err
+		}
+		limit := map[string]int{"d+": 400, "w+": MaxWeekInterval, "m+": 12}[name.text]
+		num, err := p.number(p.tokens[1], between(1, limit), "неверный диапазон интервала")
+		if err != nil {
+			return nil, err
+		}
+		return FloatingRule{Unit: name.text[:1], Interval: num}, nil
+	case "b":
+		if err := p.expect(2, 2, "не указан интервал в рабочих днях"); err != nil {
+			return nil, err
+		}
+		num, err := p.number(p.tokens[1], between(1, 400), "неверный диапазон рабочих дней")
+		if err != nil {
+			return nil, err
+		}
+		return BusinessDayRule{Interval: num}, nil
+	case "y":
+		if err := p.expect(1, 1, ""); err != nil {
+			return nil, err
+		}
+		return YearlyRule{}, nil
+	case "w":
+		if err := p.expect(2, 3, "не указаны дни недели"); err != nil {
+			return nil, err
+		}
+		rule := WeeklyRule{Interval: 1}
+		var err error
+		rule.Days, err = p.list(p.tokens[1], between(1, 7), "неверный диапазон дней недели")
+		if err != nil {
+			return nil, err
+		}
+		if len(p.tokens) == 3 {
+			tok := p.tokens[2]
+			if !strings.HasPrefix(tok.text, "/") {
+				return nil, p.errorAt(tok.off, tok.text, "ожидается интервал в неделях /N")
+			}
+			rule.Interval, err = p.number(token{tok.text[1:], tok.off + 1}, between(1, MaxWeekInterval), "неверный диапазон интервала в неделях")
+			if err != nil {
+				return nil, err
+			}
+		}
+		return rule, nil
+	case "m":
+		if err := p.expect(2, 3, "не указаны дни месяца"); err != nil {
+			return nil, err
+		}
+		var rule MonthlyRule
+		var err error
+		off := p.tokens[1].off
+		for _, item := range strings.Split(p.tokens[1].text, ",") {
+			if n, day, found := strings.Cut(item, ":"); found {
+				var weekDay OrdinalWeekDay
+				weekDay.N, err = p.number(token{n, off}, func(num int) bool {
+					return num >= 1 && num <= 5 || num == -1
+				}, "неверный порядковый номер дня недели")
+				if err != nil {
+					return nil, err
+				}
+				weekDay.Day, err = p.number(token{day, off + len(n) + 1}, between(1, 7), "неверный диапазон дней недели")
+				if err != nil {
+					return nil, err
+				}
+				rule.WeekDays = append(rule.WeekDays, weekDay)
+			} else {
+				num, err := p.number(token{item, off}, func(num int) bool {
+					return num >= 1 && num <= 31 || num == -1 || num == -2
+				}, "неверный диапазон дней")
+				if err != nil {
+					return nil, err
+				}
+				rule.Days = append(rule.Days, num)
+			}
+			off += len(item) + 1
+		}
+		if len(p.tokens) == 3 {
+			rule.Months, err = p.list(p.tokens[2], between(1, 12), "неверный диапазон месяцев")
+			if err != nil {
+				return nil, err
+			}
+		}
+		return rule, nil
+	case "h", "min":
+		if err := p.expect(2, 2, "не указан интервал"); err != nil {
+			return nil, err
+		}
+		limit := 168
+		if name.text == "min" {
+			limit = 1440
+		}
+		num, err := p.number(p.tokens[1], between(1, limit), "неверный диапазон интервала")
+		if err != nil {
+			return nil, err
+		}
+		if name.text == "min" {
+			return MinutelyRule{Interval: num}, nil
+		}
+		return HourlyRule{Interval: num}, nil
+	}
+
+	return nil, p.errorAt(name.off, name.text, "неподдерживаемый формат повторения")
+}
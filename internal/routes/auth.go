@@ -0,0 +1,161 @@
+package routes
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+
+	"github.com/PhilippElizarov/go_final_project/internal/auth"
+	"github.com/PhilippElizarov/go_final_project/internal/model"
+)
+
+func (s *Server) handleSignIn(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	var response model.Response
+	var signIn model.SignIn
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	if s.password == "" {
+		response.Error = "Аутентификация не настроена"
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&response)
+		return
+	}
+
+	_, err := buf.ReadFrom(r.Body)
+	if err != nil {
+		response.Error = err.Error()
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&response)
+		return
+	}
+
+	if err = json.Unmarshal(buf.Bytes(), &signIn); err != nil {
+		response.Error = err.Error()
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&response)
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(signIn.Password), []byte(s.password)) != 1 {
+		response.Error = "Неверный пароль"
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(&response)
+		return
+	}
+
+	response.Token, err = auth.Sign(s.password, s.clock.Now())
+	if err != nil {
+		response.Error = err.Error()
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(&response)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&response)
+}
+
+// authorize пропускает запрос только с действительным токеном в cookie token.
+// Если пароль не задан, аутентификация отключена.
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return s.checkToken(next, false)
+}
+
+// authorizeFeed дополнительно принимает токен подписки из параметра запроса
+// token для клиентов, подписывающихся на ленту по ссылке. Токен входа в
+// параметре не принимается: он попадает в журналы и историю браузера.
+func (s *Server) authorizeFeed(next http.Handler) http.Handler {
+	return s.checkToken(next, true)
+}
+
+func (s *Server) checkToken(next http.Handler, allowQuery bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.password == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var token string
+		cookie, err := r.Cookie("token")
+		if err == nil {
+			token = cookie.Value
+		}
+
+		if token != "" {
+			err = auth.Verify(s.password, token, s.clock.Now())
+		} else if feed := r.URL.Query().Get("token"); allowQuery && feed != "" {
+			err = s.verifyFeedToken(feed)
+		} else {
+			err = auth.ErrInvalidToken
+		}
+
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(&model.Response{Error: "Требуется аутентификация"})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) verifyFeedToken(token string) error {
+	if s.feedTokens == nil {
+		return auth.ErrInvalidToken
+	}
+	ok, err := s.feedTokens.IsFeedToken(auth.FeedTokenHash(s.password, token))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return auth.ErrInvalidToken
+	}
+	return nil
+}
+
+// handleFeedToken выпускает токен подписки на календарь взамен прежнего.
+func (s *Server) handleFeedToken(w http.ResponseWriter, r *http.Request) {
+	var response model.Response
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	if s.password == "" {
+		response.Error = "Аутентификация не настроена"
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&response)
+		return
+	}
+
+	token, err := auth.NewFeedToken()
+	if err == nil {
+		err = s.feedTokens.ReplaceFeedToken(auth.FeedTokenHash(s.password, token))
+	}
+	if err != nil {
+		response.Error = err.Error()
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(&response)
+		return
+	}
+
+	response.Token = token
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&response)
+}
+
+// handleRevokeFeedToken отзывает токен подписки на календарь.
+func (s *Server) handleRevokeFeedToken(w http.ResponseWriter, r *http.Request) {
+	var response model.Response
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	if err := s.feedTokens.RevokeFeedTokens(); err != nil {
+		response.Error = err.Error()
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(&response)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&response)
+}
@@ -0,0 +1,346 @@
+package routes
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PhilippElizarov/go_final_project/internal/model"
+	"github.com/PhilippElizarov/go_final_project/internal/nextdate"
+)
+
+var csvHeader = []string{"id", "date", "title", "comment", "repeat", "time", "until", "remaining", "catchup", "shift_base"}
+
+// maxImportErrors ограничивает число строк с ошибками в ответе.
+const maxImportErrors = 10
+
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+
+	switch format {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="scheduler.json"`)
+
+		// задачи пишутся по одной, чтобы не собирать всю выгрузку в памяти
+		io.WriteString(w, `{"tasks":[`)
+		enc := json.NewEncoder(w)
+		first := true
+		err := s.store.ForEachTask(func(task model.Task) error {
+			if !first {
+				if _, err := io.WriteString(w, ","); err != nil {
+					return err
+				}
+			}
+			first = false
+			return enc.Encode(&task)
+		})
+		if err != nil {
+			// заголовок уже отправлен, поэтому остаётся только оборвать ответ
+			panic(http.ErrAbortHandler)
+		}
+
+		io.WriteString(w, `],"exceptions":[`)
+		if s.exceptions != nil {
+			first = true
+			err = s.exceptions.ForEachException(func(e model.TaskException) error {
+				if !first {
+					if _, err := io.WriteString(w, ","); err != nil {
+						return err
+					}
+				}
+				first = false
+				return enc.Encode(&e)
+			})
+			if err != nil {
+				panic(http.ErrAbortHandler)
+			}
+		}
+		io.WriteString(w, "]}\n")
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=UTF-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="scheduler.csv"`)
+
+		// исключения для повторений в CSV не выгружаются
+		cw := csv.NewWriter(w)
+		cw.Write(csvHeader)
+		err := s.store.ForEachTask(func(task model.Task) error {
+			remaining := ""
+			if task.Remaining > 0 {
+				remaining = strconv.Itoa(task.Remaining)
+			}
+			return cw.Write([]string{task.ID, task.Date, task.Title, task.Comment, task.Repeat, task.Time, task.Until, remaining, task.CatchUp, task.ShiftBase})
+		})
+		cw.Flush()
+		if err != nil || cw.Error() != nil {
+			panic(http.ErrAbortHandler)
+		}
+	default:
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&model.Response{Error: "Неподдерживаемый формат выгрузки"})
+	}
+}
+
+func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
+	var response model.Response
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	mode := r.URL.Query().Get("mode")
+	if mode != "" && mode != "merge" && mode != "replace" {
+		response.Error = "Режим импорта должен быть merge или replace"
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&response)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+			format = "csv"
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+
+	var tasks []model.Task
+	var exceptions []model.TaskException
+	var err error
+	switch format {
+	case "json":
+		tasks, exceptions, err = readJSONTasks(r.Body)
+	case "csv":
+		tasks, err = readCSVTasks(r.Body)
+		if err == nil && mode == "replace" {
+			exceptions, err = s.keptExceptions(tasks)
+		}
+	default:
+		err = errors.New("Неподдерживаемый формат импорта")
+	}
+	if err != nil {
+		response.Error = err.Error()
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&response)
+		return
+	}
+
+	if err = validateTasks(tasks); err != nil {
+		response.Error = err.Error()
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&response)
+		return
+	}
+
+	if err = validateImportExceptions(exceptions, tasks); err != nil {
+		response.Error = err.Error()
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&response)
+		return
+	}
+
+	if err = s.backup.ImportTasks(tasks, exceptions, mode == "replace"); err != nil {
+		response.Error = err.Error()
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(&response)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&model.ImportResult{Imported: len(tasks)})
+}
+
+func readJSONTasks(r io.Reader) ([]model.Task, []model.TaskException, error) {
+	var backup struct {
+		Tasks      []model.Task          `json:"tasks"`
+		Exceptions []model.TaskException `json:"exceptions"`
+	}
+
+	if err := json.NewDecoder(r).Decode(&backup); err != nil {
+		return nil, nil, err
+	}
+
+	return backup.Tasks, backup.Exceptions, nil
+}
+
+// keptExceptions возвращает исключения повторяющихся задач из tasks: в CSV
+// исключений нет, и при замене они сохраняются для задач, которые остаются.
+func (s *Server) keptExceptions(tasks []model.Task) ([]model.TaskException, error) {
+	repeating := make(map[string]bool)
+	for _, task := range tasks {
+		if task.ID != "" && task.Repeat != "" {
+			repeating[task.ID] = true
+		}
+	}
+
+	var exceptions []model.TaskException
+	if s.exceptions == nil {
+		return exceptions, nil
+	}
+	err := s.exceptions.ForEachException(func(e model.TaskException) error {
+		if repeating[e.TaskID] {
+			exceptions = append(exceptions, e)
+		}
+		return nil
+	})
+	return exceptions, err
+}
+
+func readCSVTasks(r io.Reader) ([]model.Task, error) {
+	cr := csv.NewReader(r)
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.TrimSpace(strings.ToLower(name))] = i
+	}
+	for _, name := range []string{"date", "title"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("в заголовке CSV нет столбца %s", name)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok {
+			return ""
+		}
+		return record[i]
+	}
+
+	var tasks []model.Task
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		task := model.Task{
+			ID:        field(record, "id"),
+			Date:      field(record, "date"),
+			Title:     field(record, "title"),
+			Comment:   field(record, "comment"),
+			Repeat:    field(record, "repeat"),
+			Time:      field(record, "time"),
+			Until:     field(record, "until"),
+			CatchUp:   field(record, "catchup"),
+			ShiftBase: field(record, "shift_base"),
+		}
+		if remaining := field(record, "remaining"); remaining != "" {
+			task.Remaining, err = strconv.Atoi(remaining)
+			if err != nil {
+				return nil, fmt.Errorf("некорректное число повторений %s", remaining)
+			}
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, nil
+}
+
+// validateTasks проверяет все строки до записи, чтобы импорт либо
+// выполнился целиком, либо не изменил базу.
+func validateTasks(tasks []model.Task) error {
+	var problems []string
+	ids := make(map[string]bool)
+
+	for i, task := range tasks {
+		var problem string
+
+		switch {
+		case task.ID != "" && !isTaskID(task.ID):
+			problem = "некорректный id " + task.ID
+		case task.ID != "" && ids[task.ID]:
+			problem = "повторяющийся id " + task.ID
+		case task.Title == "":
+			problem = "не указан заголовок задачи"
+		}
+
+		if problem == "" {
+			if _, err := time.Parse(model.TimeTemplate, task.Date); err != nil {
+				problem = "некорректная дата " + task.Date
+			} else if _, err := time.Parse(model.ClockTemplate, task.Time); task.Time != "" && err != nil {
+				problem = "некорректное время " + task.Time
+			} else if task.Repeat != "" {
+				if _, err := nextdate.Parse(task.Repeat); err != nil {
+					problem = "некорректное правило повторения " + task.Repeat + ": " + err.Error()
+				}
+			}
+			if err := validateEnd(task); problem == "" && err != nil {
+				problem = err.Error()
+			}
+			if _, err := validateCatchUp(task); problem == "" && err != nil {
+				problem = err.Error()
+			}
+			if _, err := shiftBase(task); problem == "" && err != nil {
+				problem = err.Error()
+			}
+		}
+
+		ids[task.ID] = true
+
+		if problem != "" {
+			problems = append(problems, fmt.Sprintf("задача %d: %s", i+1, problem))
+		}
+		if len(problems) == maxImportErrors {
+			break
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// validateImportExceptions проверяет исключения из выгрузки: каждое должно
+// относиться к повторяющейся задаче с id из той же выгрузки.
+func validateImportExceptions(exceptions []model.TaskException, tasks []model.Task) error {
+	var problems []string
+	repeating := make(map[string]bool)
+	for _, task := range tasks {
+		if task.ID != "" && task.Repeat != "" {
+			repeating[task.ID] = true
+		}
+	}
+
+	for i, e := range exceptions {
+		var problem string
+		if err := validateException(e); err != nil {
+			problem = err.Error()
+		} else if !repeating[e.TaskID] {
+			problem = "в выгрузке нет повторяющейся задачи " + e.TaskID
+		}
+
+		if problem != "" {
+			problems = append(problems, fmt.Sprintf("исключение %d: %s", i+1, problem))
+		}
+		if len(problems) == maxImportErrors {
+			break
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+func isTaskID(id string) bool {
+	num, err := strconv.ParseInt(id, 10, 64)
+	return err == nil && num > 0
+}
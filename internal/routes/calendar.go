@@ -0,0 +1,208 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PhilippElizarov/go_final_project/internal/ical"
+	"github.com/PhilippElizarov/go_final_project/internal/model"
+	"github.com/PhilippElizarov/go_final_project/internal/nextdate"
+)
+
+func (s *Server) handleCalendar(w http.ResponseWriter, r *http.Request) {
+	var events []ical.Event
+
+	err := s.store.ForEachTask(func(task model.Task) error {
+		event, err := ical.TaskEvent(task, s.calendar)
+		if err != nil {
+			return err
+		}
+		if task.Repeat != "" {
+			skip, err := s.skipDates(task.ID)
+			if err != nil {
+				return err
+			}
+			event = ical.Exclude(event, skip)
+		}
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := ical.Encode(&buf, events, s.nowIn(r)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=UTF-8")
+	w.Header().Set("Content-Disposition", `inline; filename="scheduler.ics"`)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// maxUploadSize ограничивает размер загружаемого файла.
+const maxUploadSize = 10 << 20
+
+func (s *Server) handleImportCalendar(w http.ResponseWriter, r *http.Request) {
+	var response model.Response
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			response.Error = err.Error()
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&response)
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	items, err := ical.Decode(body, s.location(r))
+	if err != nil {
+		response.Error = err.Error()
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&response)
+		return
+	}
+
+	report := model.ImportReport{Items: []model.ImportItem{}}
+	for _, item := range items {
+		result := s.importItem(item, s.nowIn(r))
+		switch result.Status {
+		case model.ImportCreated:
+			report.Created++
+		case model.ImportSkipped:
+			report.Skipped++
+		case model.ImportUnsupported:
+			report.Unsupported++
+		}
+		report.Items = append(report.Items, result)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&report)
+}
+
+func (s *Server) importItem(item ical.Item, now time.Time) model.ImportItem {
+	result := model.ImportItem{UID: item.UID, Summary: item.Summary}
+
+	skip := func(status string, reason string) model.ImportItem {
+		result.Status = status
+		result.Reason = reason
+		return result
+	}
+
+	if item.Summary == "" {
+		return skip(model.ImportSkipped, "Не указан заголовок")
+	}
+	if item.Start.IsZero() {
+		return skip(model.ImportSkipped, "Не указана дата")
+	}
+	if item.Status == "CANCELLED" || item.Status == "COMPLETED" {
+		return skip(model.ImportSkipped, "Статус "+item.Status)
+	}
+
+	task := model.Task{
+		Date:    item.Start.Format(model.TimeTemplate),
+		Title:   item.Summary,
+		Comment: item.Description,
+	}
+	if item.Timed {
+		task.Time = item.Start.Format(model.ClockTemplate)
+	}
+
+	if item.RRule != "" {
+		repeat, err := ical.FromRRule(item.RRule, item.Start)
+		switch {
+		case err == nil:
+			task.Repeat, task.Until, task.Remaining = repeat.Rule, repeat.Until, repeat.Remaining
+		case errors.Is(err, ical.ErrUnsupportedRRule):
+			// правило без короткой записи сохраняется как RRULE, вместе с COUNT и UNTIL
+			rrule := item.RRule
+			if !nextdate.IsRRule(rrule) {
+				rrule = "RRULE:" + rrule
+			}
+			rule, errRule := nextdate.Parse(rrule)
+			if errRule != nil {
+				return skip(model.ImportUnsupported, err.Error()+": "+errRule.Error())
+			}
+			task.Repeat = rule.String()
+		default:
+			return skip(model.ImportUnsupported, err.Error())
+		}
+	}
+
+	if task.Until != "" && task.Until < now.Format(model.TimeTemplate) {
+		return skip(model.ImportSkipped, "Повторения закончились")
+	}
+	// прошедшие повторения уже израсходовали часть COUNT
+	if task.Remaining > 0 && task.Date < now.Format(model.TimeTemplate) {
+		passed, err := s.passedOccurrences(task, now)
+		if err != nil {
+			return skip(model.ImportUnsupported, err.Error())
+		}
+		if passed >= task.Remaining {
+			return skip(model.ImportSkipped, "Повторения закончились")
+		}
+		task.Remaining -= passed
+	}
+
+	// прошедшие разовые события не переносятся на сегодня, как при ручном добавлении
+	if task.Repeat == "" && task.Date < now.Format(model.TimeTemplate) {
+		return skip(model.ImportSkipped, "Событие в прошлом")
+	}
+
+	if err := s.prepareTask(&task, now); errors.Is(err, nextdate.ErrNoOccurrences) {
+		return skip(model.ImportSkipped, "Повторения закончились")
+	} else if err != nil {
+		return skip(model.ImportUnsupported, err.Error())
+	}
+
+	response, err := s.store.AddTask(task)
+	if err != nil {
+		return skip(model.ImportSkipped, err.Error())
+	}
+
+	result.Status = model.ImportCreated
+	result.ID = response.Id
+	return result
+}
+
+// passedOccurrences считает повторения задачи до первого повторения после
+// now — тех, что пропускаются, когда прошедшая дата задачи переносится вперёд.
+func (s *Server) passedOccurrences(task model.Task, now time.Time) (int, error) {
+	start, err := task.Start()
+	if err != nil {
+		return 0, err
+	}
+	rule, err := nextdate.Parse(task.Repeat)
+	if err != nil {
+		return 0, err
+	}
+	rule = nextdate.WithCalendar(rule, s.calendar)
+
+	upcoming, ok := nextdate.Occurrences(start, rule).After(now)
+	c := nextdate.Occurrences(start, rule)
+	passed := 0
+	for ; passed < task.Remaining; passed++ {
+		next, more := c.Next()
+		if !more || ok && !next.Before(upcoming) {
+			break
+		}
+	}
+	return passed, nil
+}
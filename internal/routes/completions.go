@@ -0,0 +1,73 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/PhilippElizarov/go_final_project/internal/model"
+)
+
+func (s *Server) handleTaskHistory(w http.ResponseWriter, r *http.Request) {
+	var response model.Response
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		response.Error = "Не указан идентификатор задачи"
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&response)
+		return
+	}
+
+	completions, err := s.completions.TaskHistory(id)
+	if err != nil {
+		response.Error = err.Error()
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(&response)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&completions)
+}
+
+func (s *Server) handleGetCompletions(w http.ResponseWriter, r *http.Request) {
+	var response model.Response
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	from := r.URL.Query().Get("from")
+	to := r.URL.Query().Get("to")
+
+	// границы — дни в часовом поясе запроса, обе включаются целиком
+	var fromAt, toAt time.Time
+	for _, date := range []string{from, to} {
+		if date == "" {
+			continue
+		}
+		day, err := time.ParseInLocation(model.TimeTemplate, date, s.location(r))
+		if err != nil {
+			response.Error = "Некорректная дата периода: " + date
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&response)
+			return
+		}
+		if date == from {
+			fromAt = day
+		}
+		if date == to {
+			toAt = day.AddDate(0, 0, 1)
+		}
+	}
+
+	completions, err := s.completions.GetCompletions(fromAt, toAt)
+	if err != nil {
+		response.Error = err.Error()
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(&response)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&completions)
+}
@@ -0,0 +1,133 @@
+package routes
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/PhilippElizarov/go_final_project/internal/model"
+)
+
+// skipDates возвращает пропущенные даты повторений задачи; без хранилища
+// исключений пропущенных дат нет.
+func (s *Server) skipDates(taskID string) ([]string, error) {
+	if s.exceptions == nil {
+		return nil, nil
+	}
+	exceptions, err := s.exceptions.TaskExceptions(taskID)
+	if err != nil {
+		return nil, err
+	}
+	return exceptions.SkipDates(), nil
+}
+
+func (s *Server) handleGetExceptions(w http.ResponseWriter, r *http.Request) {
+	var response model.Response
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		response.Error = "Не указан идентификатор задачи"
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&response)
+		return
+	}
+
+	exceptions, err := s.exceptions.TaskExceptions(id)
+	if err != nil {
+		response.Error = err.Error()
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(&response)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&exceptions)
+}
+
+// handleAddException пропускает одно повторение задачи или переопределяет
+// его дату и заголовок; повторение задаётся датой, на которую оно выпадает.
+func (s *Server) handleAddException(w http.ResponseWriter, r *http.Request) {
+	var response model.Response
+	var e model.TaskException
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+		response.Error = err.Error()
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&response)
+		return
+	}
+
+	if err := validateException(e); err != nil {
+		response.Error = err.Error()
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&response)
+		return
+	}
+
+	response, err := s.exceptions.AddException(e)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, sql.ErrNoRows) {
+			err = errors.New("Задача не найдена")
+			status = http.StatusNotFound
+		}
+		response.Error = err.Error()
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(&response)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&response)
+}
+
+func validateException(e model.TaskException) error {
+	if e.TaskID == "" {
+		return errors.New("Не указан идентификатор задачи")
+	}
+	if _, err := time.Parse(model.TimeTemplate, e.Date); err != nil {
+		return errors.New("Некорректная дата повторения: " + e.Date)
+	}
+
+	switch e.Kind {
+	case model.ExceptionSkip:
+	case model.ExceptionOverride:
+		if e.NewDate == "" && e.Title == "" {
+			return errors.New("Для переопределения нужна новая дата или заголовок")
+		}
+		if e.NewDate != "" {
+			if _, err := time.Parse(model.TimeTemplate, e.NewDate); err != nil {
+				return errors.New("Некорректная новая дата: " + e.NewDate)
+			}
+		}
+	default:
+		return errors.New("Неизвестный вид исключения: " + e.Kind)
+	}
+
+	return nil
+}
+
+func (s *Server) handleDeleteException(w http.ResponseWriter, r *http.Request) {
+	var response model.Response
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	err := s.exceptions.DeleteException(r.URL.Query().Get("id"))
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, sql.ErrNoRows) {
+			err = errors.New("Исключение не найдено")
+			status = http.StatusNotFound
+		}
+		response.Error = err.Error()
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(&response)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&response)
+}
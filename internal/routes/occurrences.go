@@ -0,0 +1,99 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PhilippElizarov/go_final_project/internal/model"
+	"github.com/PhilippElizarov/go_final_project/internal/nextdate"
+)
+
+const (
+	DefaultOccurrences = 10
+	MaxOccurrences     = 100
+)
+
+// handleOccurrences возвращает ближайшие повторения задачи с датой date и
+// правилом repeat, чтобы показать их до сохранения задачи.
+func (s *Server) handleOccurrences(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	fail := func(msg string) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&model.Response{Error: msg})
+	}
+
+	now := s.nowIn(r)
+	if value := query.Get("now"); value != "" {
+		date, err := time.ParseInLocation(model.TimeTemplate, value, now.Location())
+		if err != nil {
+			fail("Некорректная текущая дата " + value)
+			return
+		}
+		now = date
+	}
+
+	task := model.Task{
+		Date:   query.Get("date"),
+		Time:   query.Get("time"),
+		Repeat: query.Get("repeat"),
+	}
+	if task.Date == "" {
+		task.Date = now.Format(model.TimeTemplate)
+	}
+	if task.Time != "" {
+		if _, err := time.Parse(model.ClockTemplate, task.Time); err != nil {
+			fail("Некорректное время " + task.Time)
+			return
+		}
+	}
+
+	start, err := task.Start()
+	if err != nil {
+		fail("Некорректная дата " + task.Date)
+		return
+	}
+	start = time.Date(start.Year(), start.Month(), start.Day(), start.Hour(), start.Minute(), 0, 0, now.Location())
+
+	rule, err := nextdate.Parse(task.Repeat)
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+	rule = nextdate.WithCalendar(rule, s.calendar)
+
+	count := DefaultOccurrences
+	if value := query.Get("count"); value != "" {
+		count, err = strconv.Atoi(value)
+		if err != nil || count < 1 {
+			fail("Некорректное число повторений")
+			return
+		}
+	}
+	if count > MaxOccurrences {
+		count = MaxOccurrences
+	}
+
+	var until time.Time
+	if value := query.Get("until"); value != "" {
+		until, err = time.ParseInLocation(model.TimeTemplate, value, now.Location())
+		if err != nil {
+			fail("Некорректная дата until " + value)
+			return
+		}
+		// граница включается целиком
+		until = until.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+
+	occurrences := []model.Occurrence{}
+	for _, date := range nextdate.Upcoming(now, start, rule, count, until) {
+		task.SetStart(date)
+		occurrences = append(occurrences, model.Occurrence{Date: task.Date, Time: task.Time})
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(occurrences)
+}
@@ -0,0 +1,72 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/PhilippElizarov/go_final_project/internal/model"
+	"github.com/PhilippElizarov/go_final_project/internal/nextdate"
+)
+
+// handleValidateRepeat проверяет правило повторения из поля repeat и
+// возвращает его канонический вид или место ошибки.
+func handleValidateRepeat(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Repeat string `json:"repeat"`
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&model.Response{Error: err.Error()})
+		return
+	}
+
+	var result model.RepeatValidation
+
+	rule, err := nextdate.Parse(request.Repeat)
+	if err != nil {
+		var parseErr *nextdate.ParseError
+		if errors.As(err, &parseErr) {
+			result.Error = parseErr.Msg
+			result.Position = &parseErr.Pos
+			result.Token = parseErr.Token
+		} else {
+			result.Error = err.Error()
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(&result)
+		return
+	}
+
+	result.Valid = true
+	result.Rule = rule.String()
+	result.Type, _, _ = strings.Cut(result.Rule, " ")
+	if _, ok := rule.(nextdate.RRule); ok {
+		result.Type = "rrule"
+	}
+	result.Text = nextdate.Describe(rule, locale(r))
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&result)
+}
+
+// locale выбирает язык описаний: параметр lang или заголовок Accept-Language.
+func locale(r *http.Request) string {
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		return lang
+	}
+	return r.Header.Get("Accept-Language")
+}
+
+// describeRepeat заполняет описание правила повторения задачи.
+func describeRepeat(task *model.Task, locale string) {
+	if task.Repeat == "" {
+		return
+	}
+	if rule, err := nextdate.Parse(task.Repeat); err == nil {
+		task.RepeatText = nextdate.Describe(rule, locale)
+	}
+}
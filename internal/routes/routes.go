@@ -3,41 +3,170 @@ package routes
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
-	"github.com/PhilippElizarov/go_final_project/internal/database"
+	"github.com/PhilippElizarov/go_final_project/internal/clock"
 	"github.com/PhilippElizarov/go_final_project/internal/model"
+	"github.com/PhilippElizarov/go_final_project/internal/natural"
 	"github.com/PhilippElizarov/go_final_project/internal/nextdate"
 	"github.com/go-chi/chi/v5"
 	_ "github.com/mattn/go-sqlite3"
 )
 
-func NewRouter() http.Handler {
-	r := chi.NewRouter()
+// TaskRepository — хранилище задач, без которого сервер не работает.
+// Остальные возможности подключаются отдельными интерфейсами ниже: если
+// хранилище их не реализует, их маршруты не регистрируются.
+type TaskRepository interface {
+	AddTask(task model.Task) (model.Response, error)
+	GetTasks(filter model.TaskFilter) (model.Tasks, error)
+	GetTaskByID(id string) (model.Task, error)
+	UpdateTask(task model.Task) error
+	DoneTask(id string, doneAt time.Time) error
+	DeleteTask(id string) error
+	ForEachTask(fn func(task model.Task) error) error
+}
+
+// FeedTokenRepository хранит токены подписки на календарь.
+type FeedTokenRepository interface {
+	ReplaceFeedToken(hash string) error
+	RevokeFeedTokens() error
+	IsFeedToken(hash string) (bool, error)
+}
+
+// CompletionRepository хранит историю выполнения и снимки для отмены.
+type CompletionRepository interface {
+	UndoTask(id string) (model.Response, error)
+	TaskHistory(id string) (model.Completions, error)
+	GetCompletions(from time.Time, to time.Time) (model.Completions, error)
+}
+
+// ExceptionRepository хранит исключения для повторений задач.
+type ExceptionRepository interface {
+	TaskExceptions(taskID string) (model.TaskExceptions, error)
+	ForEachException(fn func(e model.TaskException) error) error
+	AddException(e model.TaskException) (model.Response, error)
+	DeleteException(id string) error
+}
+
+// BackupRepository восстанавливает задачи из резервной копии.
+type BackupRepository interface {
+	ImportTasks(tasks []model.Task, exceptions []model.TaskException, replace bool) error
+}
+
+type Config struct {
+	Password string
+	// Location — часовой пояс по умолчанию, в котором определяется
+	// сегодняшняя дата; если не задан, используется пояс сервера.
+	Location *time.Location
+	// Clock — источник текущего времени; если не задан, системные часы.
+	Clock clock.Clock
+	// Debug разрешает подменять текущее время заголовком X-Fake-Now.
+	Debug bool
+	// Calendar — производственный календарь для правил с рабочими днями;
+	// если не задан, нерабочие только субботы и воскресенья.
+	Calendar nextdate.BusinessCalendar
+}
+
+type Server struct {
+	store       TaskRepository
+	completions CompletionRepository
+	exceptions  ExceptionRepository
+	feedTokens  FeedTokenRepository
+	backup      BackupRepository
+	password    string
+	loc         *time.Location
+	clock       clock.Clock
+	debug       bool
+	calendar    nextdate.BusinessCalendar
+	router      chi.Router
+}
+
+func NewServer(store TaskRepository, cfg Config) *Server {
+	s := &Server{
+		store:    store,
+		password: cfg.Password,
+		loc:      cfg.Location,
+		clock:    cfg.Clock,
+		debug:    cfg.Debug,
+		calendar: cfg.Calendar,
+		router:   chi.NewRouter(),
+	}
+
+	if s.loc == nil {
+		s.loc = time.Local
+	}
+	if s.clock == nil {
+		s.clock = clock.System
+	}
+	s.completions, _ = store.(CompletionRepository)
+	s.exceptions, _ = store.(ExceptionRepository)
+	s.feedTokens, _ = store.(FeedTokenRepository)
+	s.backup, _ = store.(BackupRepository)
+
+	r := s.router
 
 	r.Handle("/*", http.FileServer(http.Dir("./web")))
 	r.Handle("/js/*", http.StripPrefix("/js/", http.FileServer(http.Dir("./web/js"))))
 	r.Handle("/css/*", http.StripPrefix("/css/", http.FileServer(http.Dir("./web/css"))))
 
-	r.Get("/api/nextdate", handleNextDate)
-	r.Post("/api/task", handleAddTask)
-	r.Get("/api/tasks", handleGetTasks)
-	r.Get("/api/task", handleGetTaskByID)
-	r.Put("/api/task", handleUpdateTask)
-	r.Post("/api/task/done", handleDoneTask)
-	r.Delete("/api/task", handleDeleteTask)
+	r.With(s.withLocation, s.withFakeNow).Get("/api/nextdate", s.handleNextDate)
+	r.Post("/api/repeat/validate", handleValidateRepeat)
+	r.With(s.withLocation, s.withFakeNow).Get("/api/occurrences", s.handleOccurrences)
+	r.Post("/api/signin", s.handleSignIn)
+	// календарные клиенты не передают cookie, поэтому токен подписки можно
+	// указать в параметре token
+	r.With(s.authorizeFeed, s.withFakeNow).Get("/api/calendar.ics", s.handleCalendar)
+
+	r.Group(func(r chi.Router) {
+		r.Use(s.authorize)
+		r.Use(s.withLocation)
+		r.Use(s.withFakeNow)
+
+		r.Post("/api/task", s.handleAddTask)
+		r.Get("/api/tasks", s.handleGetTasks)
+		r.Get("/api/task", s.handleGetTaskByID)
+		r.Put("/api/task", s.handleUpdateTask)
+		r.Post("/api/task/dry-run", s.handleDryRunTask)
+		r.Post("/api/task/done", s.handleDoneTask)
+		r.Delete("/api/task", s.handleDeleteTask)
+		r.Post("/api/import/ics", s.handleImportCalendar)
+		r.Get("/api/export", s.handleExport)
+		if s.completions != nil {
+			r.Post("/api/task/undo", s.handleUndoTask)
+			r.Get("/api/task/history", s.handleTaskHistory)
+			r.Get("/api/completions", s.handleGetCompletions)
+		}
+		if s.exceptions != nil {
+			r.Get("/api/task/exceptions", s.handleGetExceptions)
+			r.Post("/api/task/exceptions", s.handleAddException)
+			r.Delete("/api/task/exceptions", s.handleDeleteException)
+		}
+		if s.backup != nil {
+			r.Post("/api/import", s.handleImport)
+		}
+		if s.feedTokens != nil {
+			r.Post("/api/calendar/token", s.handleFeedToken)
+			r.Delete("/api/calendar/token", s.handleRevokeFeedToken)
+		}
+	})
 
-	return r
+	return s
 }
 
-func handleDeleteTask(w http.ResponseWriter, r *http.Request) {
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.ServeHTTP(w, r)
+}
+
+func (s *Server) handleDeleteTask(w http.ResponseWriter, r *http.Request) {
 	var response model.Response
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
 	id := r.URL.Query().Get("id")
 
-	err := database.TaskStorage.DeleteTask(id)
+	err := s.store.DeleteTask(id)
 	if err != nil {
 		response.Error = err.Error()
 		json.NewEncoder(w).Encode(&response)
@@ -49,13 +178,30 @@ func handleDeleteTask(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-func handleDoneTask(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleUndoTask(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	id := r.URL.Query().Get("id")
+
+	response, err := s.completions.UndoTask(id)
+	if err != nil {
+		response.Error = err.Error()
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&response)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&response)
+}
+
+func (s *Server) handleDoneTask(w http.ResponseWriter, r *http.Request) {
 	var response model.Response
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
 	id := r.URL.Query().Get("id")
 
-	err := database.TaskStorage.DoneTask(id)
+	err := s.store.DoneTask(id, s.nowIn(r))
 	if err != nil {
 		response.Error = err.Error()
 		json.NewEncoder(w).Encode(&response)
@@ -67,7 +213,7 @@ func handleDoneTask(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-func handleUpdateTask(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
 	var buf bytes.Buffer
 	var response model.Response
 	var task model.Task
@@ -102,45 +248,14 @@ func handleUpdateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	dateNow := time.Now().Format(model.TimeTemplate)
-
-	if task.Date == "" {
-		task.Date = dateNow
-	}
-
-	var date_ time.Time
-
-	date_, err = time.Parse(model.TimeTemplate, task.Date)
-	if err != nil {
+	if err = s.prepareTask(&task, s.nowIn(r)); err != nil {
 		response.Error = err.Error()
-		json.NewEncoder(w).Encode(&response)
 		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
-
-	dateNow_, err := time.Parse(model.TimeTemplate, dateNow)
-	if err != nil {
-		response.Error = err.Error()
 		json.NewEncoder(w).Encode(&response)
-		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	if date_.Compare(dateNow_) == -1 {
-		if task.Repeat == "" {
-			task.Date = dateNow_.Format(model.TimeTemplate)
-		} else {
-			task.Date, err = nextdate.NextDate(dateNow_, task.Date, task.Repeat)
-			if err != nil {
-				response.Error = err.Error()
-				json.NewEncoder(w).Encode(&response)
-				w.WriteHeader(http.StatusBadRequest)
-				return
-			}
-		}
-	}
-
-	err = database.TaskStorage.UpdateTask(task)
+	err = s.store.UpdateTask(task)
 	if err != nil {
 		response.Error = err.Error()
 		json.NewEncoder(w).Encode(&response)
@@ -152,12 +267,12 @@ func handleUpdateTask(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-func handleGetTaskByID(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleGetTaskByID(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("id")
 	var response model.Response
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
-	task, err := database.TaskStorage.GetTaskByID(id)
+	task, err := s.store.GetTaskByID(id)
 	if err != nil {
 		response.Error = err.Error()
 		json.NewEncoder(w).Encode(&response)
@@ -165,17 +280,34 @@ func handleGetTaskByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	describeRepeat(&task, locale(r))
+
 	json.NewEncoder(w).Encode(&task)
 	w.WriteHeader(http.StatusFound)
 }
 
-func handleGetTasks(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleGetTasks(w http.ResponseWriter, r *http.Request) {
 	var response model.Response
 	var tasks model.Tasks
 
-	search := r.URL.Query().Get("search")
+	filter := model.TaskFilter{
+		Search: r.URL.Query().Get("search"),
+		Cursor: r.URL.Query().Get("cursor"),
+	}
+
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		num, err := strconv.Atoi(limit)
+		if err != nil || num < 1 {
+			w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+			w.WriteHeader(http.StatusBadRequest)
+			response.Error = "Некорректный размер страницы"
+			json.NewEncoder(w).Encode(&response)
+			return
+		}
+		filter.Limit = num
+	}
 
-	tasks, err := database.TaskStorage.GetTasks(search)
+	tasks, err := s.store.GetTasks(filter)
 	if err != nil {
 		response.Error = err.Error()
 		json.NewEncoder(w).Encode(&response)
@@ -187,6 +319,14 @@ func handleGetTasks(w http.ResponseWriter, r *http.Request) {
 		tasks.Tasks = []interface{}{}
 	}
 
+	lang := locale(r)
+	for i, item := range tasks.Tasks {
+		if task, ok := item.(model.Task); ok {
+			describeRepeat(&task, lang)
+			tasks.Tasks[i] = task
+		}
+	}
+
 	resp, err := json.Marshal(tasks)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -197,7 +337,7 @@ func handleGetTasks(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write(resp)
 }
 
-func handleAddTask(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleAddTask(w http.ResponseWriter, r *http.Request) {
 	var task model.Task
 	var buf bytes.Buffer
 	var response model.Response
@@ -226,15 +366,14 @@ func handleAddTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	dateNow := time.Now().Format(model.TimeTemplate)
-
-	if task.Date == "" {
-		task.Date = dateNow
+	if err = s.prepareTask(&task, s.nowIn(r)); err != nil {
+		response.Error = err.Error()
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&response)
+		return
 	}
 
-	var date_ time.Time
-
-	date_, err = time.Parse(model.TimeTemplate, task.Date)
+	response, err = s.store.AddTask(task)
 	if err != nil {
 		response.Error = err.Error()
 		json.NewEncoder(w).Encode(&response)
@@ -242,7 +381,7 @@ func handleAddTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	dateNow_, err := time.Parse(model.TimeTemplate, dateNow)
+	_, err = json.Marshal(response)
 	if err != nil {
 		response.Error = err.Error()
 		json.NewEncoder(w).Encode(&response)
@@ -250,53 +389,191 @@ func handleAddTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if date_.Compare(dateNow_) == -1 { // || date_.Compare(dateNow_) == 0 {
-		if task.Repeat == "" {
-			task.Date = dateNow_.Format(model.TimeTemplate)
-		} else {
-			task.Date, err = nextdate.NextDate(dateNow_, task.Date, task.Repeat)
+	json.NewEncoder(w).Encode(&response)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// prepareTask подставляет сегодняшнюю дату вместо пустой и переносит
+// прошедшую дату на сегодня или на ближайшее повторение по правилу.
+// Сегодняшняя дата берётся из now в часовом поясе запроса. Дату и правило
+// можно указать фразой («завтра», «каждые 2 недели по понедельникам»),
+// они переводятся в формат 20060102 и в правило nextdate.
+func (s *Server) prepareTask(task *model.Task, now time.Time) error {
+	dateNow := now.Format(model.TimeTemplate)
+
+	if task.Date == "" {
+		task.Date = dateNow
+	} else if _, err := time.Parse(model.TimeTemplate, task.Date); err != nil {
+		date, errPhrase := natural.ParseDate(task.Date, now)
+		if errPhrase != nil {
+			return err
+		}
+		task.Date = date.Format(model.TimeTemplate)
+	}
+
+	if task.Time != "" {
+		if _, err := time.Parse(model.ClockTemplate, task.Time); err != nil {
+			return err
+		}
+	}
+
+	start, err := task.Start()
+	if err != nil {
+		return err
+	}
+
+	var rule nextdate.Rule
+	if task.Repeat != "" {
+		rule, err = nextdate.Parse(task.Repeat)
+		if err != nil {
+			repeat, phraseStart, errPhrase := natural.ParseRepeat(task.Repeat, start)
+			if errPhrase != nil {
+				return err
+			}
+			task.Repeat = repeat
+			task.SetStart(phraseStart)
+			start = phraseStart
+			rule, _ = nextdate.Parse(repeat)
+		}
+		base, err := shiftBase(*task)
+		if err != nil {
+			return err
+		}
+		rule = nextdate.WithShiftBase(nextdate.WithCalendar(rule, s.calendar), base)
+		// у сохранённой задачи пропущенные повторения не назначаются снова
+		if task.ID != "" {
+			skip, err := s.skipDates(task.ID)
 			if err != nil {
-				response.Error = err.Error()
-				json.NewEncoder(w).Encode(&response)
-				w.WriteHeader(http.StatusBadRequest)
-				return
+				return err
 			}
+			rule = nextdate.WithExceptions(rule, skip)
 		}
 	}
 
-	response, err = database.TaskStorage.AddTask(task)
+	if err := validateEnd(*task); err != nil {
+		return err
+	}
+	mode, err := validateCatchUp(*task)
 	if err != nil {
-		response.Error = err.Error()
-		json.NewEncoder(w).Encode(&response)
+		return err
+	}
+
+	// в режиме next-after-previous прошедшая дата остаётся: с неё начнётся
+	// выполнение пропущенных повторений
+	if task.Date < dateNow && mode != nextdate.NextAfterPrevious {
+		if rule == nil {
+			task.Date = dateNow
+		} else {
+			c := nextdate.Occurrences(start, rule)
+			next, ok := c.After(now)
+			if !ok {
+				return nextdate.ErrNoOccurrences
+			}
+			if advanced := c.Rule(); advanced.String() != rule.String() {
+				task.Repeat = advanced.String()
+			}
+			task.SetStart(next)
+			task.SetShiftBase(nextdate.ShiftBase(c.Rule(), next))
+		}
+	}
+
+	if task.Until != "" && task.Date > task.Until {
+		return errors.New("дата задачи позже даты окончания повторений")
+	}
+
+	return nil
+}
+
+// shiftBase разбирает дату повторения до переноса на рабочий день.
+func shiftBase(task model.Task) (time.Time, error) {
+	if task.ShiftBase == "" {
+		return time.Time{}, nil
+	}
+	base, err := time.Parse(model.TimeTemplate, task.ShiftBase)
+	if err != nil {
+		return base, errors.New("некорректная дата до переноса на рабочий день " + task.ShiftBase)
+	}
+	return base, nil
+}
+
+// validateEnd проверяет условия окончания повторений: дату until и число
+// оставшихся повторений remaining.
+func validateEnd(task model.Task) error {
+	if task.Until == "" && task.Remaining == 0 {
+		return nil
+	}
+	if task.Repeat == "" {
+		return errors.New("окончание повторений задаётся только для повторяющейся задачи")
+	}
+	if task.Remaining < 0 {
+		return errors.New("число повторений не может быть отрицательным")
+	}
+	if task.Until != "" {
+		if _, err := time.Parse(model.TimeTemplate, task.Until); err != nil {
+			return errors.New("некорректная дата окончания повторений " + task.Until)
+		}
+	}
+	return nil
+}
+
+// validateCatchUp проверяет режим выполнения пропущенных повторений.
+func validateCatchUp(task model.Task) (nextdate.CatchUp, error) {
+	if task.CatchUp != "" && task.Repeat == "" {
+		return "", errors.New("режим выполнения пропущенных повторений задаётся только для повторяющейся задачи")
+	}
+	return nextdate.ParseCatchUp(task.CatchUp)
+}
+
+// handleDryRunTask показывает, как будет сохранена задача: с датой и
+// правилом после разбора фраз и переноса прошедшей даты, но ничего не записывает.
+func (s *Server) handleDryRunTask(w http.ResponseWriter, r *http.Request) {
+	var task model.Task
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&model.Response{Error: err.Error()})
 		return
 	}
 
-	_, err = json.Marshal(response)
-	if err != nil {
-		response.Error = err.Error()
-		json.NewEncoder(w).Encode(&response)
+	if err := s.prepareTask(&task, s.nowIn(r)); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(&model.Response{Error: err.Error()})
 		return
 	}
 
-	json.NewEncoder(w).Encode(&response)
-	w.WriteHeader(http.StatusCreated)
+	describeRepeat(&task, locale(r))
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(&task)
 }
 
-func handleNextDate(w http.ResponseWriter, r *http.Request) {
+// handleNextDate возвращает следующую дату задачи; без параметра now
+// отсчёт идёт от сегодняшнего дня в часовом поясе запроса.
+func (s *Server) handleNextDate(w http.ResponseWriter, r *http.Request) {
 	now := r.URL.Query().Get("now")
 	date := r.URL.Query().Get("date")
 	repeat := r.URL.Query().Get("repeat")
 
+	if now == "" {
+		now = s.nowIn(r).Format(model.TimeTemplate)
+	}
 	nowDate, err := time.Parse(model.TimeTemplate, now)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	nextDate, err := nextdate.NextDate(nowDate, date, repeat)
+	mode, err := nextdate.ParseCatchUp(r.URL.Query().Get("catchup"))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	nextDate, err := mode.NextDate(nowDate, date, repeat, s.calendar)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
@@ -0,0 +1,82 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/PhilippElizarov/go_final_project/internal/clock"
+	"github.com/PhilippElizarov/go_final_project/internal/model"
+)
+
+type contextKey int
+
+const (
+	locationKey contextKey = iota
+	fakeNowKey
+)
+
+// withLocation определяет часовой пояс запроса: параметр tz, заголовок
+// X-Timezone или пояс сервера по умолчанию.
+func (s *Server) withLocation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("tz")
+		if name == "" {
+			name = r.Header.Get("X-Timezone")
+		}
+
+		if name == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&model.Response{Error: "Неизвестный часовой пояс " + name})
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), locationKey, loc)))
+	})
+}
+
+func (s *Server) location(r *http.Request) *time.Location {
+	if loc, ok := r.Context().Value(locationKey).(*time.Location); ok {
+		return loc
+	}
+	return s.loc
+}
+
+// nowIn возвращает текущий момент в часовом поясе запроса: от него
+// зависит, какая дата считается сегодняшней.
+func (s *Server) nowIn(r *http.Request) time.Time {
+	if now, ok := r.Context().Value(fakeNowKey).(time.Time); ok {
+		return now.In(s.location(r))
+	}
+	return s.clock.Now().In(s.location(r))
+}
+
+// withFakeNow подменяет текущее время запроса значением заголовка X-Fake-Now.
+// Вне отладочного режима заголовок игнорируется.
+func (s *Server) withFakeNow(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value := r.Header.Get("X-Fake-Now")
+		if value == "" || !s.debug {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		now, err := clock.Parse(value, s.location(r))
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(&model.Response{Error: err.Error()})
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), fakeNowKey, now)))
+	})
+}
@@ -33,6 +33,9 @@ func requestJSON(apipath string, values map[string]any, method string) ([]byte,
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if len(FakeNow) > 0 {
+		req.Header.Set("X-Fake-Now", FakeNow)
+	}
 
 	client := &http.Client{}
 	if len(Token) > 0 {
@@ -0,0 +1,40 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignIn(t *testing.T) {
+	m, err := postJSON("api/signin", map[string]any{
+		"password": Password + "-неверный",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+
+	e, ok := m["error"]
+	assert.False(t, !ok || len(fmt.Sprint(e)) == 0, "Ожидается ошибка для неверного пароля")
+	assert.Empty(t, m["token"])
+
+	if len(Password) == 0 {
+		return
+	}
+
+	m, err = postJSON("api/signin", map[string]any{
+		"password": Password,
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, m["token"])
+
+	// без cookie с токеном задачи недоступны
+	saved := Token
+	Token = ""
+	defer func() { Token = saved }()
+
+	m, err = postJSON("api/task?id=1", nil, http.MethodGet)
+	assert.NoError(t, err)
+	e, ok = m["error"]
+	assert.False(t, !ok || len(fmt.Sprint(e)) == 0, "Ожидается ошибка без токена")
+}
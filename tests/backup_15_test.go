@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackup(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	date := time.Now().AddDate(0, 0, 3).Format(`20060102`)
+	id := addTask(t, task{
+		date:    date,
+		title:   "Сделать резервную копию",
+		comment: "с запятой, и \"кавычками\"",
+		repeat:  "d 7",
+	})
+	defer requestJSON("api/task?id="+id, nil, http.MethodDelete)
+
+	body, err := requestJSON("api/export?format=csv", nil, http.MethodGet)
+	assert.NoError(t, err)
+	records, err := csv.NewReader(strings.NewReader(string(body))).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"id", "date", "title", "comment", "repeat", "time", "until", "remaining", "catchup", "shift_base"}, records[0])
+	assert.Contains(t, records, []string{id, date, "Сделать резервную копию", "с запятой, и \"кавычками\"", "d 7", "", "", "", "", ""})
+
+	body, err = requestJSON("api/export", nil, http.MethodGet)
+	assert.NoError(t, err)
+	var backup map[string][]map[string]string
+	assert.NoError(t, json.Unmarshal(body, &backup))
+	before, err := count(db)
+	assert.NoError(t, err)
+	assert.Len(t, backup["tasks"], before)
+
+	// ошибка в любой строке отменяет весь импорт
+	ret, err := postJSON("api/import", map[string]any{"tasks": []map[string]any{
+		{"id": id, "date": date, "title": "Изменённый заголовок"},
+		{"date": "20240192", "title": "Плохая дата"},
+		{"date": date, "title": "Плохое правило", "repeat": "ooops"},
+	}}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Contains(t, ret["error"], "задача 2")
+	assert.Contains(t, ret["error"], "задача 3")
+
+	var task Task
+	assert.NoError(t, db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id))
+	assert.Equal(t, "Сделать резервную копию", task.Title)
+
+	ret, err = postJSON("api/import?mode=merge", map[string]any{"tasks": []map[string]any{
+		{"id": id, "date": date, "title": "Изменённый заголовок", "repeat": "d 7"},
+		{"date": date, "title": "Новая задача из копии"},
+	}}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["error"])
+	assert.Equal(t, float64(2), ret["imported"])
+
+	assert.NoError(t, db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id))
+	assert.Equal(t, "Изменённый заголовок", task.Title)
+	assert.NoError(t, db.Get(&task, `SELECT * FROM scheduler WHERE title=?`, "Новая задача из копии"))
+	defer db.Exec(`DELETE FROM scheduler WHERE title=?`, "Новая задача из копии")
+
+	// полная выгрузка восстанавливается в режиме replace
+	tasks := make([]map[string]any, 0, len(backup["tasks"]))
+	for _, v := range backup["tasks"] {
+		tasks = append(tasks, map[string]any{"id": v["id"], "date": v["date"], "title": v["title"],
+			"comment": v["comment"], "repeat": v["repeat"]})
+	}
+	ret, err = postJSON("api/import?mode=replace", map[string]any{"tasks": tasks}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["error"])
+
+	after, err := count(db)
+	assert.NoError(t, err)
+	assert.Equal(t, before, after)
+	assert.NoError(t, db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id))
+	assert.Equal(t, "Сделать резервную копию", task.Title)
+
+	body, err = postRaw("api/import", "text/csv", "id,date,title,comment,repeat\n"+
+		id+","+date+",Заголовок из CSV,,d 7\n")
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), `"imported":1`)
+	assert.NoError(t, db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id))
+	assert.Equal(t, "Заголовок из CSV", task.Title)
+}
+
+func TestBackupExceptions(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	day := func(n int) string {
+		return now.AddDate(0, 0, n).Format(`20060102`)
+	}
+
+	id := addTask(t, task{date: day(0), title: "Полить цветы", repeat: "d 1"})
+	defer requestJSON("api/task?id="+id, nil, http.MethodDelete)
+
+	ret := addException(t, map[string]any{"task_id": id, "date": day(2), "kind": "skip"})
+	assert.Empty(t, ret["error"])
+	ret = addException(t, map[string]any{"task_id": id, "date": day(3), "kind": "override", "new_date": day(4)})
+	assert.Empty(t, ret["error"])
+
+	body, err := requestJSON("api/export", nil, http.MethodGet)
+	assert.NoError(t, err)
+	var backup struct {
+		Exceptions []map[string]string `json:"exceptions"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &backup))
+	var exported []map[string]string
+	for _, e := range backup.Exceptions {
+		if e["task_id"] == id {
+			exported = append(exported, e)
+		}
+	}
+	if assert.Len(t, exported, 2) {
+		assert.Equal(t, day(2), exported[0]["date"])
+		assert.Equal(t, "override", exported[1]["kind"])
+		assert.Equal(t, day(4), exported[1]["new_date"])
+	}
+
+	// исключение без задачи в выгрузке не импортируется
+	ret, err = postJSON("api/import", map[string]any{
+		"tasks":      []map[string]any{{"date": day(0), "title": "Без id", "repeat": "d 1"}},
+		"exceptions": []map[string]any{{"task_id": id, "date": day(2), "kind": "skip"}},
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Contains(t, ret["error"], "исключение 1")
+
+	// режим replace восстанавливает исключения вместе с задачами
+	body, err = postRaw("api/import?mode=replace", "application/json", string(body))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(body), `"error"`)
+
+	restored := getExceptions(t, id)
+	if assert.Len(t, restored.Exceptions, 2) {
+		assert.Equal(t, day(2), restored.Exceptions[0]["date"])
+		assert.Equal(t, "skip", restored.Exceptions[0]["kind"])
+		assert.Equal(t, day(3), restored.Exceptions[1]["date"])
+		assert.Equal(t, day(4), restored.Exceptions[1]["new_date"])
+	}
+
+	// в CSV исключений нет: замена сохраняет исключения оставшихся задач
+	body, err = requestJSON("api/export?format=csv", nil, http.MethodGet)
+	assert.NoError(t, err)
+	csvBackup := string(body)
+	body, err = postRaw("api/import?mode=replace", "text/csv", csvBackup)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(body), `"error"`)
+	assert.Len(t, getExceptions(t, id).Exceptions, 2)
+
+	// а исключения задач, которых в CSV нет, удаляются вместе с ними
+	var lines []string
+	for _, line := range strings.Split(csvBackup, "\n") {
+		if !strings.HasPrefix(line, id+",") {
+			lines = append(lines, line)
+		}
+	}
+	body, err = postRaw("api/import?mode=replace", "text/csv", strings.Join(lines, "\n"))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(body), `"error"`)
+	var n int
+	assert.NoError(t, db.Get(&n, `SELECT COUNT(*) FROM task_exceptions WHERE task_id=?`, id))
+	assert.Equal(t, 0, n)
+}
@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PhilippElizarov/go_final_project/internal/holiday"
+	"github.com/PhilippElizarov/go_final_project/internal/nextdate"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBusinessDays(t *testing.T) {
+	cal, err := holiday.Load("ru", "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	day := func(s string) time.Time {
+		d, err := time.Parse(`20060102`, s)
+		assert.NoError(t, err)
+		return d
+	}
+	assert.False(t, cal.IsBusinessDay(day("20240101")))
+	assert.True(t, cal.IsBusinessDay(day("20240427")))
+	assert.False(t, cal.IsBusinessDay(day("20240428")))
+	assert.True(t, cal.IsBusinessDay(day("20240426")))
+
+	tbl := []struct {
+		now    string
+		date   string
+		repeat string
+		want   string
+	}{
+		{"20240425", "20240425", "b 3", "20240502"},
+		{"20241220", "20241201", "m 1 +b", "20250109"},
+		{"20241220", "20241201", "m 1 -b", "20241228"},
+		{"20241220", "20241201", "m 1 !b", "20250401"},
+		{"20250101", "20230508", "y +b", "20250512"},
+		{"20240126", "20240126", "w 6 -b", "20240202"},
+		{"20240419", "20240419", "w 6 -b", "20240427"},
+		{"20240126", "20240126", "b 1", "20240129"},
+	}
+	for _, v := range tbl {
+		next, err := nextdate.SkipToFuture.NextDate(day(v.now), v.date, v.repeat, cal)
+		if assert.NoError(t, err, v.repeat) {
+			assert.Equal(t, v.want, next, "%s от %s", v.repeat, v.date)
+		}
+	}
+
+	// календарь задаётся правилу, а не всему процессу: без него нерабочие
+	// только субботы и воскресенья
+	next, err := nextdate.NextDate(day("20231229"), "20231229", "b 1")
+	assert.NoError(t, err)
+	assert.Equal(t, "20240101", next)
+	next, err = nextdate.SkipToFuture.NextDate(day("20231229"), "20231229", "b 1", cal)
+	assert.NoError(t, err)
+	assert.Equal(t, "20240109", next)
+
+	for _, repeat := range []string{"+b", "b", "b 401", "h 2 +b", "min 5 !b", "b 2 +b", "d 1 +b +b", "y -x"} {
+		_, err := nextdate.Parse(repeat)
+		assert.Error(t, err, repeat)
+	}
+
+	rule, err := nextdate.Parse("m 15 -b")
+	assert.NoError(t, err)
+	assert.Equal(t, "m 15 -b", rule.String())
+	assert.Equal(t, "15-го числа каждого месяца, с переносом на предыдущий рабочий день", nextdate.Describe(rule, "ru"))
+	assert.Equal(t, "on the 15th of every month, moved to the previous business day", nextdate.Describe(rule, "en"))
+
+	rule, err = nextdate.Parse("b 3")
+	assert.NoError(t, err)
+	assert.Equal(t, "каждые 3 рабочих дня", nextdate.Describe(rule, "ru"))
+	assert.Equal(t, "every 3 business days", nextdate.Describe(rule, "en"))
+}
+
+// TestShiftedRuleSteps проверяет, что перенос на рабочий день не сдвигает
+// расписание при последовательном выполнении задачи.
+func TestShiftedRuleSteps(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	cal, err := holiday.Load("ru", "")
+	if !assert.NoError(t, err) {
+		return
+	}
+	day := func(s string) time.Time {
+		d, err := time.Parse(`20060102`, s)
+		assert.NoError(t, err)
+		return d
+	}
+
+	// 23 февраля — праздник: каждый год задача переносится на ближайший
+	// рабочий день после него, а не на 26-е, как в 2024 году
+	c := nextdate.Occurrences(day("20230223"), nextdate.WithCalendar(mustParse(t, "y +b"), cal))
+	c.Next()
+	for _, want := range []string{"20240226", "20250224", "20260224"} {
+		first := day(want[:4] + "0223")
+		for !cal.IsBusinessDay(first) {
+			first = first.AddDate(0, 0, 1)
+		}
+		next, ok := c.Next()
+		assert.True(t, ok)
+		assert.Equal(t, first, next, want)
+	}
+
+	// d 10 +b от субботы 3 февраля, перенесённой на понедельник 5-го:
+	// повторения идут от 3-го — 13-го, 23-го (праздник, перенос на 26-е), 4 марта
+	ret, err := postJSON("api/task", map[string]any{
+		"date":       "20240205",
+		"title":      "Сдать показания",
+		"repeat":     "d 10 +b",
+		"shift_base": "20240203",
+		"catchup":    "next-after-previous",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["error"])
+	id, _ := ret["id"].(string)
+	defer requestJSON("api/task?id="+id, nil, http.MethodDelete)
+
+	for _, want := range []struct{ date, base string }{
+		{"20240213", ""},
+		{"20240226", "20240223"},
+		{"20240304", ""},
+	} {
+		ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+
+		var stored Task
+		assert.NoError(t, db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id))
+		assert.Equal(t, want.date, stored.Date)
+		assert.Equal(t, want.base, stored.ShiftBase)
+	}
+}
+
+func TestHolidayCalendarFile(t *testing.T) {
+	cal, err := holiday.Parse(strings.NewReader("# тест\n20240610\n\n+20240615\n"))
+	assert.NoError(t, err)
+	assert.False(t, cal.IsBusinessDay(time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, cal.IsBusinessDay(time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC)))
+	assert.False(t, cal.IsBusinessDay(time.Date(2024, 6, 16, 0, 0, 0, 0, time.UTC)))
+
+	// за годы вне календаря предупреждение приходит один раз
+	assert.Equal(t, 2024, cal.First)
+	assert.Equal(t, 2024, cal.Last)
+	var missing []int
+	cal.Missing = func(year int) { missing = append(missing, year) }
+	assert.True(t, cal.IsBusinessDay(time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC)))
+	assert.False(t, cal.IsBusinessDay(time.Date(2025, 6, 14, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, cal.IsBusinessDay(time.Date(2024, 6, 11, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, []int{2025}, missing)
+
+	_, err = holiday.Parse(strings.NewReader("20240610\n10.06.2024\n"))
+	assert.ErrorContains(t, err, "строка 2")
+
+	_, err = holiday.Load("xx", "")
+	assert.Error(t, err)
+}
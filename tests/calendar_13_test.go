@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PhilippElizarov/go_final_project/internal/routes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalendar(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	weekly := addTask(t, task{
+		date:    time.Now().Format(`20060102`),
+		title:   "Планёрка, по понедельникам; и средам",
+		comment: "Переговорная\nна 3 этаже",
+		repeat:  "w 1,3",
+	})
+	defer requestJSON("api/task?id="+weekly, nil, http.MethodDelete)
+
+	// 29 февраля повторяется 1 марта, что нельзя выразить через RRULE
+	res, err := db.Exec(`INSERT INTO scheduler (date, title, comment, repeat) 
+	VALUES ('20280229', 'Високосный день', '', 'y')`)
+	assert.NoError(t, err)
+	leap, err := res.LastInsertId()
+	assert.NoError(t, err)
+	defer requestJSON(fmt.Sprint("api/task?id=", leap), nil, http.MethodDelete)
+
+	body, err := requestJSON("api/calendar.ics", nil, http.MethodGet)
+	assert.NoError(t, err)
+	cal := strings.ReplaceAll(string(body), "\r\n ", "")
+
+	assert.True(t, strings.HasPrefix(cal, "BEGIN:VCALENDAR\r\n"))
+	assert.True(t, strings.HasSuffix(cal, "END:VCALENDAR\r\n"))
+
+	events := strings.Split(cal, "BEGIN:VEVENT\r\n")
+	find := func(id string) string {
+		for _, e := range events {
+			if strings.Contains(e, "UID:task-"+id+"@") {
+				return e
+			}
+		}
+		return ""
+	}
+
+	event := find(weekly)
+	if assert.NotEmpty(t, event) {
+		assert.Contains(t, event, `SUMMARY:Планёрка\, по понедельникам\; и средам`+"\r\n")
+		assert.Contains(t, event, `DESCRIPTION:Переговорная\nна 3 этаже`+"\r\n")
+		assert.Contains(t, event, "RRULE:FREQ=WEEKLY;BYDAY=MO,WE\r\n")
+	}
+
+	event = find(fmt.Sprint(leap))
+	if assert.NotEmpty(t, event) {
+		assert.Contains(t, event, "DTSTART;VALUE=DATE:20280229\r\n")
+		assert.NotContains(t, event, "RRULE:")
+		assert.Contains(t, event, "RDATE;VALUE=DATE:20290301,20300301\r\n")
+	}
+
+	for _, line := range strings.Split(string(body), "\r\n") {
+		assert.LessOrEqual(t, len(line), 75)
+	}
+}
+
+func TestCalendarFeedToken(t *testing.T) {
+	store := newMemStore()
+	srv := httptest.NewServer(routes.NewServer(store, routes.Config{Password: "секрет"}))
+	defer srv.Close()
+
+	do := func(method string, path string, cookie string) (int, map[string]any) {
+		req, err := http.NewRequest(method, srv.URL+path, nil)
+		assert.NoError(t, err)
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "token", Value: cookie})
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		var m map[string]any
+		if resp.Header.Get("Content-Type") == "application/json; charset=UTF-8" {
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&m))
+		}
+		return resp.StatusCode, m
+	}
+
+	resp, err := http.Post(srv.URL+"/api/signin", "application/json", strings.NewReader(`{"password":"секрет"}`))
+	assert.NoError(t, err)
+	var signIn map[string]string
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&signIn))
+	resp.Body.Close()
+	login := signIn["token"]
+	assert.NotEmpty(t, login)
+
+	// токен входа в параметре запроса не принимается
+	status, _ := do(http.MethodGet, "/api/calendar.ics?token="+url.QueryEscape(login), "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// токен подписки выпускается только после входа
+	status, _ = do(http.MethodPost, "/api/calendar/token", "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, m := do(http.MethodPost, "/api/calendar/token", login)
+	assert.Equal(t, http.StatusOK, status)
+	feed, _ := m["token"].(string)
+	assert.NotEmpty(t, feed)
+
+	status, _ = do(http.MethodGet, "/api/calendar.ics?token="+url.QueryEscape(feed), "")
+	assert.Equal(t, http.StatusOK, status)
+
+	// токен подписки открывает только календарь
+	status, _ = do(http.MethodGet, "/api/tasks?token="+url.QueryEscape(feed), "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, _ = do(http.MethodGet, "/api/tasks", feed)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// новый токен заменяет прежний, а отзыв отключает подписку
+	_, m = do(http.MethodPost, "/api/calendar/token", login)
+	renewed, _ := m["token"].(string)
+	status, _ = do(http.MethodGet, "/api/calendar.ics?token="+url.QueryEscape(feed), "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, _ = do(http.MethodGet, "/api/calendar.ics?token="+url.QueryEscape(renewed), "")
+	assert.Equal(t, http.StatusOK, status)
+
+	status, _ = do(http.MethodDelete, "/api/calendar/token", login)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = do(http.MethodGet, "/api/calendar.ics?token="+url.QueryEscape(renewed), "")
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
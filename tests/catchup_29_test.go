@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatchUp(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	for _, v := range []struct {
+		catchup string
+		date    string
+		repeat  string
+		want    string
+	}{
+		{"", "20240101", "d 3", "20240128"},
+		{"skip-to-future", "20240101", "d 3", "20240128"},
+		{"next-after-previous", "20240101", "d 3", "20240104"},
+		{"fixed-interval-from-completion", "20240101", "d 3", "20240129"},
+		// выполнена заранее: отсчёт всё равно идёт от дня выполнения
+		{"fixed-interval-from-completion", "20240201", "d 3", "20240129"},
+		{"next-after-previous", "20240101", "w 1", "20240108"},
+		{"fixed-interval-from-completion", "20240101", "w 1", "20240129"},
+		{"next-after-previous", "20240101", "FREQ=DAILY;COUNT=3", "20240102"},
+		{"skip-to-future", "20240101", "FREQ=DAILY;COUNT=3", ""},
+		{"every-day", "20240101", "d 3", ""},
+	} {
+		body, err := getBody(fmt.Sprintf("api/nextdate?now=20240126&date=%s&repeat=%s&catchup=%s",
+			v.date, url.QueryEscape(v.repeat), v.catchup))
+		assert.NoError(t, err)
+		next := strings.TrimSpace(string(body))
+		if v.want == "" {
+			assert.NotRegexp(t, `^\d{8}$`, next, v.catchup+" "+v.repeat)
+			continue
+		}
+		assert.Equal(t, v.want, next, v.catchup+" "+v.repeat)
+	}
+
+	// некорректный now — ошибка без даты в ответе
+	resp, err := http.Get(getURL("api/nextdate?now=2024-01-26&date=20240101&repeat=d+3"))
+	if assert.NoError(t, err) {
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.NotRegexp(t, `\n\d{8}$`, string(body))
+	}
+
+	now := time.Now()
+	day := func(n int) string {
+		return now.AddDate(0, 0, n).Format(`20060102`)
+	}
+	done := func(id string) {
+		ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+	}
+
+	// просроченная дата сохраняется, и пропущенные дни выполняются по очереди
+	ret, err := postJSON("api/task", map[string]any{
+		"date": day(-3), "title": "Отчёт", "repeat": "d 1", "catchup": "next-after-previous",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["error"])
+	id, _ := ret["id"].(string)
+	defer requestJSON("api/task?id="+id, nil, http.MethodDelete)
+
+	var stored Task
+	assert.NoError(t, db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id))
+	assert.Equal(t, day(-3), stored.Date)
+	assert.Equal(t, "next-after-previous", stored.CatchUp)
+
+	done(id)
+	assert.NoError(t, db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id))
+	assert.Equal(t, day(-2), stored.Date)
+
+	ret, err = postJSON("api/task", map[string]any{
+		"date": day(2), "title": "Сменить фильтр", "repeat": "d 7", "catchup": "fixed-interval-from-completion",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["error"])
+	id, _ = ret["id"].(string)
+	defer requestJSON("api/task?id="+id, nil, http.MethodDelete)
+
+	done(id)
+	assert.NoError(t, db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id))
+	assert.Equal(t, day(7), stored.Date)
+
+	for _, v := range []map[string]any{
+		{"date": day(0), "title": "Неизвестный режим", "repeat": "d 1", "catchup": "every-day"},
+		{"date": day(0), "title": "Разовая", "catchup": "next-after-previous"},
+	} {
+		ret, err := postJSON("api/task", v, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"], v["title"])
+	}
+}
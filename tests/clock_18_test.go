@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PhilippElizarov/go_final_project/internal/clock"
+	"github.com/PhilippElizarov/go_final_project/internal/routes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixedClock(t *testing.T) {
+	store := newMemStore()
+	now := time.Date(2024, 1, 31, 23, 30, 0, 0, time.UTC)
+	srv := httptest.NewServer(routes.NewServer(store, routes.Config{
+		Location: time.UTC,
+		Clock:    clock.Fixed(now),
+		Debug:    true,
+	}))
+	defer srv.Close()
+
+	add := func(path string, fakeNow string, values map[string]any) (int, map[string]any) {
+		data, err := json.Marshal(values)
+		assert.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, srv.URL+path, bytes.NewReader(data))
+		assert.NoError(t, err)
+		if fakeNow != "" {
+			req.Header.Set("X-Fake-Now", fakeNow)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		var m map[string]any
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&m))
+		return resp.StatusCode, m
+	}
+	date := func(m map[string]any) string {
+		id, _ := m["id"].(string)
+		return store.tasks[id].Date
+	}
+
+	_, m := add("/api/task", "", map[string]any{"title": "Без даты"})
+	assert.Equal(t, "20240131", date(m))
+
+	_, m = add("/api/task", "", map[string]any{"title": "В последний день", "date": "20240101", "repeat": "m -1"})
+	assert.Equal(t, "20240229", date(m))
+
+	_, m = add("/api/task", "", map[string]any{"title": "31 числа", "date": "20231231", "repeat": "m 31"})
+	assert.Equal(t, "20240331", date(m))
+
+	_, m = add("/api/task?tz=Asia/Tokyo", "", map[string]any{"title": "В Токио уже февраль"})
+	assert.Equal(t, "20240201", date(m))
+
+	_, m = add("/api/task", "20240229", map[string]any{"title": "Високосный день"})
+	assert.Equal(t, "20240229", date(m))
+
+	_, m = add("/api/task", "2024-12-31T23:59:00Z", map[string]any{"title": "Конец года", "date": "20240101", "repeat": "y"})
+	assert.Equal(t, "20250101", date(m))
+
+	status, m := add("/api/task", "вчера", map[string]any{"title": "Задача"})
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.NotEmpty(t, m["error"])
+
+	_, m = add("/api/task", "", map[string]any{"title": "Выполнить"})
+	id, _ := m["id"].(string)
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/task/done?id="+id, nil)
+	assert.NoError(t, err)
+	req.Header.Set("X-Fake-Now", "2024-03-01T10:00:00Z")
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.True(t, store.doneAt.Equal(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)))
+
+	// без отладочного режима заголовок игнорируется
+	store = newMemStore()
+	srv.Config.Handler = routes.NewServer(store, routes.Config{
+		Location: time.UTC,
+		Clock:    clock.Fixed(now),
+	})
+	_, m = add("/api/task", "20240229", map[string]any{"title": "Без отладки"})
+	assert.Equal(t, "20240131", date(m))
+}
+
+func TestFakeNowPreview(t *testing.T) {
+	now := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+	srv := httptest.NewServer(routes.NewServer(newMemStore(), routes.Config{
+		Location: time.UTC,
+		Clock:    clock.Fixed(now),
+		Debug:    true,
+	}))
+	defer srv.Close()
+
+	get := func(path string, fakeNow string) string {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		assert.NoError(t, err)
+		if fakeNow != "" {
+			req.Header.Set("X-Fake-Now", fakeNow)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		var buf bytes.Buffer
+		_, err = buf.ReadFrom(resp.Body)
+		assert.NoError(t, err)
+		return buf.String()
+	}
+
+	// без now дата отсчитывается от часов сервера или от X-Fake-Now
+	assert.Equal(t, "20240131", get("/api/nextdate?date=20240101&repeat=d+1", ""))
+	assert.Equal(t, "20240301", get("/api/nextdate?date=20240101&repeat=d+1", "20240301"))
+	assert.Equal(t, "20240126", get("/api/nextdate?now=20240126&date=20240101&repeat=d+1", "20240301"))
+
+	var occurrences []map[string]string
+	assert.NoError(t, json.Unmarshal([]byte(get("/api/occurrences?date=20240101&repeat=d+1&count=1", "")), &occurrences))
+	assert.Equal(t, "20240131", occurrences[0]["date"])
+	assert.NoError(t, json.Unmarshal([]byte(get("/api/occurrences?date=20240101&repeat=d+1&count=1", "20240301")), &occurrences))
+	assert.Equal(t, "20240301", occurrences[0]["date"])
+}
@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type completions struct {
+	Completions []map[string]string `json:"completions"`
+	Error       string              `json:"error"`
+}
+
+func getCompletions(t *testing.T, apipath string) completions {
+	body, err := requestJSON(apipath, nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	var c completions
+	assert.NoError(t, json.Unmarshal(body, &c))
+	return c
+}
+
+func TestCompletions(t *testing.T) {
+	now := time.Now()
+	today := now.Format(`20060102`)
+
+	id := addTask(t, task{
+		date:   today,
+		title:  "Полить цветы",
+		repeat: "d 2",
+	})
+	defer requestJSON("api/task?id="+id, nil, http.MethodDelete)
+
+	for i := 0; i < 2; i++ {
+		ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+	}
+
+	history := getCompletions(t, "api/task/history?id="+id)
+	assert.Empty(t, history.Error)
+	if assert.Len(t, history.Completions, 2) {
+		assert.Equal(t, today, history.Completions[0]["date"])
+		// отметка о выполнении хранится в UTC
+		assert.Regexp(t, `Z$`, history.Completions[0]["done_at"])
+		assert.Equal(t, now.AddDate(0, 0, 2).Format(`20060102`), history.Completions[1]["date"])
+		assert.Equal(t, "Полить цветы", history.Completions[1]["title"])
+	}
+
+	// выполненная разовая задача удаляется, но остаётся в истории
+	single := addTask(t, task{date: today, title: "Разовое дело"})
+	ret, err := postJSON("api/task/done?id="+single, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, single)
+	assert.Len(t, getCompletions(t, "api/task/history?id="+single).Completions, 1)
+
+	var found int
+	for _, c := range getCompletions(t, "api/completions?from="+today+"&to="+today).Completions {
+		if c["task_id"] == id || c["task_id"] == single {
+			found++
+		}
+	}
+	assert.Equal(t, 3, found)
+
+	yesterday := now.AddDate(0, 0, -1).Format(`20060102`)
+	for _, c := range getCompletions(t, "api/completions?to="+yesterday).Completions {
+		assert.NotEqual(t, id, c["task_id"])
+	}
+
+	// отметка хранится в UTC, и период не зависит от пояса, в котором
+	// задачу отметили выполненной
+	tokyo := addTask(t, task{date: today, title: "Созвон с Токио"})
+	ret, err = postJSON("api/task/done?tz=Asia/Tokyo&id="+tokyo, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	history = getCompletions(t, "api/task/history?id="+tokyo)
+	if assert.Len(t, history.Completions, 1) {
+		assert.Regexp(t, `Z$`, history.Completions[0]["done_at"])
+	}
+	found = 0
+	for _, c := range getCompletions(t, "api/completions?from="+today+"&to="+today).Completions {
+		if c["task_id"] == tokyo {
+			found++
+		}
+	}
+	assert.Equal(t, 1, found)
+
+	assert.NotEmpty(t, getCompletions(t, "api/completions?from=ooops").Error)
+	assert.NotEmpty(t, getCompletions(t, "api/task/history").Error)
+}
@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/PhilippElizarov/go_final_project/internal/clock"
+	"github.com/PhilippElizarov/go_final_project/internal/nextdate"
+	"github.com/PhilippElizarov/go_final_project/internal/routes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOccurrencesCursor(t *testing.T) {
+	day := func(s string) time.Time {
+		d, err := time.Parse(`20060102`, s)
+		assert.NoError(t, err)
+		return d
+	}
+	dates := func(c *nextdate.Cursor, n int) []string {
+		var list []string
+		for len(list) < n {
+			next, ok := c.Next()
+			if !ok {
+				break
+			}
+			list = append(list, next.Format(`20060102`))
+		}
+		return list
+	}
+
+	rule, err := nextdate.Parse("w 1,3")
+	if assert.NoError(t, err) {
+		c := nextdate.Occurrences(day("20240101"), rule)
+		assert.Equal(t, []string{"20240101", "20240103", "20240108", "20240110", "20240115"}, dates(c, 5))
+	}
+
+	// повторения между двумя датами
+	rule, err = nextdate.Parse("m 1,-1")
+	if assert.NoError(t, err) {
+		c := nextdate.Occurrences(day("20230115"), rule)
+		first, ok := c.After(day("20240130"))
+		assert.True(t, ok)
+		var between []string
+		for next := first; ok && !next.After(day("20240401")); next, ok = c.Next() {
+			between = append(between, next.Format(`20060102`))
+		}
+		assert.Equal(t, []string{"20240131", "20240201", "20240229", "20240301", "20240331", "20240401"}, between)
+	}
+
+	rule, err = nextdate.Parse("FREQ=DAILY;COUNT=3")
+	if assert.NoError(t, err) {
+		c := nextdate.Occurrences(day("20240101"), rule)
+		assert.Equal(t, []string{"20240101", "20240102", "20240103"}, dates(c, 10))
+		assert.Equal(t, "FREQ=DAILY;COUNT=1", c.Rule().String())
+	}
+
+	// между 29 февраля 2096 и 2104 года восемь лет: ограничения горизонта нет
+	for _, v := range []nextDate{
+		{"20960229", "m 29 2", "21040229"},
+		{"20240101", "m 30 2", ""},
+	} {
+		next, err := nextdate.NextDate(day("20960301"), v.date, v.repeat)
+		if v.want == "" {
+			assert.Error(t, err, v.repeat)
+			continue
+		}
+		assert.NoError(t, err, v.repeat)
+		assert.Equal(t, v.want, next, v.repeat)
+	}
+}
+
+// TestTaskWithoutOccurrences проверяет, что задачу, у которой не осталось
+// повторений после сегодняшнего дня, нельзя сохранить, как и в /api/nextdate.
+func TestTaskWithoutOccurrences(t *testing.T) {
+	store := newMemStore()
+	srv := httptest.NewServer(routes.NewServer(store, routes.Config{
+		Location: time.UTC,
+		Clock:    clock.Fixed(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)),
+	}))
+	defer srv.Close()
+
+	for _, repeat := range []string{"FREQ=DAILY;COUNT=2", "m 30 2"} {
+		data, err := json.Marshal(map[string]any{"date": "20240101", "title": "Без повторений", "repeat": repeat})
+		assert.NoError(t, err)
+		resp, err := http.Post(srv.URL+"/api/task", "application/json", bytes.NewReader(data))
+		assert.NoError(t, err)
+		var m map[string]any
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&m))
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, repeat)
+		assert.Equal(t, nextdate.ErrNoOccurrences.Error(), m["error"], repeat)
+
+		resp, err = http.Get(srv.URL + "/api/nextdate?now=20240301&date=20240101&repeat=" + url.QueryEscape(repeat))
+		assert.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, repeat)
+		assert.Contains(t, string(body), nextdate.ErrNoOccurrences.Error(), repeat)
+	}
+	assert.Empty(t, store.tasks)
+}
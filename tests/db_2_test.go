@@ -11,11 +11,16 @@ import (
 )
 
 type Task struct {
-	ID      int64  `db:"id"`
-	Date    string `db:"date"`
-	Title   string `db:"title"`
-	Comment string `db:"comment"`
-	Repeat  string `db:"repeat"`
+	ID        int64  `db:"id"`
+	Date      string `db:"date"`
+	Time      string `db:"time"`
+	Title     string `db:"title"`
+	Comment   string `db:"comment"`
+	Repeat    string `db:"repeat"`
+	Until     string `db:"until"`
+	Remaining int    `db:"remaining"`
+	CatchUp   string `db:"catchup"`
+	ShiftBase string `db:"shift_base"`
 }
 
 func count(db *sqlx.DB) (int, error) {
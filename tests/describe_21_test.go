@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/PhilippElizarov/go_final_project/internal/nextdate"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribeRepeat(t *testing.T) {
+	tbl := []struct {
+		repeat string
+		ru     string
+		en     string
+	}{
+		{"d 1", "каждый день", "every day"},
+		{"d 3", "каждые 3 дня", "every 3 days"},
+		{"d 21", "каждый 21 день", "every 21 days"},
+		{"d 12", "каждые 12 дней", "every 12 days"},
+		{"y", "ежегодно", "every year"},
+		{"w 3,1", "по понедельникам и средам", "every Monday and Wednesday"},
+		{"w 1,3,5", "по понедельникам, средам и пятницам", "every Monday, Wednesday and Friday"},
+		{"w 4,1 /2", "по понедельникам и четвергам каждые 2 недели", "every 2 weeks on Monday and Thursday"},
+		{"m -1,15 2,8", "15-го числа и в последний день февраля и августа",
+			"on the 15th and the last day of February and August"},
+		{"m 1,2,-2", "1-го и 2-го числа и в предпоследний день каждого месяца",
+			"on the 1st, the 2nd and the second to last day of every month"},
+		{"m 13", "13-го числа каждого месяца", "on the 13th of every month"},
+		{"m 1:1", "в первый понедельник каждого месяца", "on the first Monday of every month"},
+		{"m 2:2,-1:5 3", "во второй вторник и в последнюю пятницу марта",
+			"on the second Tuesday and the last Friday of March"},
+		{"m 15,3:7", "15-го числа и в третье воскресенье каждого месяца",
+			"on the 15th and the third Sunday of every month"},
+		{"h 1", "каждый час", "every hour"},
+		{"h 24", "каждые 24 часа", "every 24 hours"},
+		{"min 1", "каждую минуту", "every minute"},
+		{"min 30", "каждые 30 минут", "every 30 minutes"},
+		{"min 41", "каждую 41 минуту", "every 41 minutes"},
+	}
+	for _, v := range tbl {
+		rule, err := nextdate.Parse(v.repeat)
+		if !assert.NoError(t, err, v.repeat) {
+			continue
+		}
+		assert.Equal(t, v.ru, nextdate.Describe(rule, "ru"), v.repeat)
+		assert.Equal(t, v.en, nextdate.Describe(rule, "en-US"), v.repeat)
+	}
+}
+
+func TestTaskRepeatText(t *testing.T) {
+	id := addTask(t, task{
+		date:   time.Now().Format(`20060102`),
+		title:  "Платёж по кредиту",
+		repeat: "m -1,15 2,8",
+	})
+	defer requestJSON("api/task?id="+id, nil, http.MethodDelete)
+
+	get := func(path string) map[string]string {
+		body, err := requestJSON(path, nil, http.MethodGet)
+		assert.NoError(t, err)
+		var m map[string]string
+		assert.NoError(t, json.Unmarshal(body, &m))
+		return m
+	}
+
+	m := get("api/task?id=" + id)
+	assert.Equal(t, "15-го числа и в последний день февраля и августа", m["repeat_text"])
+
+	m = get("api/task?lang=en&id=" + id)
+	assert.Equal(t, "on the 15th and the last day of February and August", m["repeat_text"])
+
+	var found bool
+	for _, task := range getTasks(t, "Платёж") {
+		if task["id"] == id {
+			found = true
+			assert.Equal(t, "15-го числа и в последний день февраля и августа", task["repeat_text"])
+		}
+	}
+	assert.True(t, found)
+}
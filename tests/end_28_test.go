@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskEnd(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	day := func(n int) string {
+		return now.AddDate(0, 0, n).Format(`20060102`)
+	}
+	done := func(id string) {
+		ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret)
+	}
+
+	ret, err := postJSON("api/task", map[string]any{
+		"date": day(0), "title": "Курс уколов", "repeat": "d 1", "remaining": 2,
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["error"])
+	id, _ := ret["id"].(string)
+	defer requestJSON("api/task?id="+id, nil, http.MethodDelete)
+
+	done(id)
+	var stored Task
+	assert.NoError(t, db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id))
+	assert.Equal(t, day(1), stored.Date)
+	assert.Equal(t, 1, stored.Remaining)
+
+	// последнее повторение выполнено: задача удаляется, история остаётся
+	done(id)
+	notFoundTask(t, id)
+	assert.Len(t, getCompletions(t, "api/task/history?id="+id).Completions, 2)
+
+	ret, err = postJSON("api/task", map[string]any{
+		"date": day(0), "title": "Акция", "repeat": "d 1", "until": day(1),
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["error"])
+	id, _ = ret["id"].(string)
+	defer requestJSON("api/task?id="+id, nil, http.MethodDelete)
+
+	done(id)
+	assert.NoError(t, db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id))
+	assert.Equal(t, day(1), stored.Date)
+	assert.Equal(t, day(1), stored.Until)
+
+	done(id)
+	notFoundTask(t, id)
+
+	for _, v := range []map[string]any{
+		{"date": day(0), "title": "Разовая", "until": day(3)},
+		{"date": day(0), "title": "Разовая", "remaining": 3},
+		{"date": day(0), "title": "Отрицательная", "repeat": "d 1", "remaining": -1},
+		{"date": day(0), "title": "Плохая дата", "repeat": "d 1", "until": "20240230"},
+		{"date": day(5), "title": "Поздно", "repeat": "d 1", "until": day(3)},
+	} {
+		ret, err := postJSON("api/task", v, http.MethodPost)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, ret["error"], v["title"])
+	}
+}
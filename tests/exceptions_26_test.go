@@ -0,0 +1,244 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PhilippElizarov/go_final_project/internal/nextdate"
+	"github.com/stretchr/testify/assert"
+)
+
+type taskExceptions struct {
+	Exceptions []map[string]string `json:"exceptions"`
+	Error      string              `json:"error"`
+}
+
+func getExceptions(t *testing.T, id string) taskExceptions {
+	body, err := requestJSON("api/task/exceptions?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	var e taskExceptions
+	assert.NoError(t, json.Unmarshal(body, &e))
+	return e
+}
+
+func addException(t *testing.T, values map[string]any) map[string]any {
+	ret, err := postJSON("api/task/exceptions", values, http.MethodPost)
+	assert.NoError(t, err)
+	return ret
+}
+
+func TestTaskExceptions(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	day := func(n int) string {
+		return now.AddDate(0, 0, n).Format(`20060102`)
+	}
+
+	id := addTask(t, task{date: day(0), title: "Зарядка", repeat: "d 1"})
+	defer requestJSON("api/task?id="+id, nil, http.MethodDelete)
+
+	// пропуск текущего повторения переносит задачу на следующее
+	ret := addException(t, map[string]any{"task_id": id, "date": day(0), "kind": "skip"})
+	assert.Empty(t, ret["error"])
+
+	var stored Task
+	assert.NoError(t, db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id))
+	assert.Equal(t, day(1), stored.Date)
+
+	ret = addException(t, map[string]any{
+		"task_id":  id,
+		"date":     day(1),
+		"kind":     "override",
+		"new_date": day(3),
+		"title":    "Зарядка в парке",
+	})
+	assert.Empty(t, ret["error"])
+	overrideID := fmt.Sprint(ret["id"])
+
+	// в списке задача показана так, как пройдёт её текущее повторение
+	page := getTasksPage(t, url.Values{"search": {"Зарядка"}})
+	var listed map[string]string
+	for _, item := range page.Tasks {
+		if item["id"] == id {
+			listed = item
+		}
+	}
+	if assert.NotNil(t, listed) {
+		assert.Equal(t, day(3), listed["date"])
+		assert.Equal(t, "Зарядка в парке", listed["title"])
+	}
+
+	// в базе задача не меняется
+	assert.NoError(t, db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id))
+	assert.Equal(t, day(1), stored.Date)
+	assert.Equal(t, "Зарядка", stored.Title)
+
+	ret = addException(t, map[string]any{"task_id": id, "date": day(2), "kind": "skip"})
+	assert.Empty(t, ret["error"])
+
+	exceptions := getExceptions(t, id)
+	assert.Empty(t, exceptions.Error)
+	if assert.Len(t, exceptions.Exceptions, 3) {
+		assert.Equal(t, day(0), exceptions.Exceptions[0]["date"])
+		assert.Equal(t, "skip", exceptions.Exceptions[0]["kind"])
+		assert.Equal(t, "override", exceptions.Exceptions[1]["kind"])
+	}
+
+	// выполнение пропускает повторение на day(2)
+	var err error
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.NoError(t, db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id))
+	assert.Equal(t, day(3), stored.Date)
+
+	history := getCompletions(t, "api/task/history?id="+id)
+	if assert.Len(t, history.Completions, 1) {
+		assert.Equal(t, "Зарядка в парке", history.Completions[0]["title"])
+	}
+
+	single := addTask(t, task{date: day(0), title: "Разовое дело"})
+	defer requestJSON("api/task?id="+single, nil, http.MethodDelete)
+
+	weekly := addTask(t, task{date: day(0), title: "Раз в неделю", repeat: "w " + weekDay(now.AddDate(0, 0, 1))})
+	defer requestJSON("api/task?id="+weekly, nil, http.MethodDelete)
+
+	for _, v := range []map[string]any{
+		{"task_id": id, "date": day(-1), "kind": "skip"},
+		{"task_id": id, "date": "ooops", "kind": "skip"},
+		{"task_id": id, "date": day(4), "kind": "move"},
+		{"task_id": id, "date": day(4), "kind": "override"},
+		{"task_id": id, "date": day(4), "kind": "override", "new_date": "ooops"},
+		{"task_id": single, "date": day(0), "kind": "skip"},
+		{"task_id": weekly, "date": day(2), "kind": "skip"},
+		{"task_id": "999999", "date": day(0), "kind": "skip"},
+		{"date": day(0), "kind": "skip"},
+	} {
+		ret := addException(t, v)
+		assert.NotEmpty(t, ret["error"], "Ожидается ошибка для %v", v)
+	}
+
+	ret, err = postJSON("api/task/exceptions?id="+overrideID, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Len(t, getExceptions(t, id).Exceptions, 2)
+
+	ret, err = postJSON("api/task/exceptions?id="+overrideID, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+
+	assert.NotEmpty(t, getExceptions(t, "").Error)
+
+	// пропущенные даты выгружаются в календарь как EXDATE
+	body, err := requestJSON("api/calendar.ics", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(string(body), "EXDATE;VALUE=DATE:"+day(0)+","+day(2)))
+}
+
+// TestExceptionsDeletedWithTask проверяет, что исключения удаляются вместе
+// с задачей и возвращаются при отмене удаления.
+func TestExceptionsDeletedWithTask(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	day := func(n int) string {
+		return now.AddDate(0, 0, n).Format(`20060102`)
+	}
+	exceptions := func(id string) int {
+		var n int
+		assert.NoError(t, db.Get(&n, `SELECT COUNT(*) FROM task_exceptions WHERE task_id=?`, id))
+		return n
+	}
+
+	id := addTask(t, task{date: day(0), title: "Пробежка", repeat: "d 1"})
+	ret := addException(t, map[string]any{"task_id": id, "date": day(1), "kind": "skip"})
+	assert.Empty(t, ret["error"])
+	assert.Equal(t, 1, exceptions(id))
+
+	ret, err := postJSON("api/task?id="+id, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Equal(t, 0, exceptions(id))
+
+	ret, err = postJSON("api/task/undo?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["error"])
+	assert.Equal(t, 1, exceptions(id))
+	requestJSON("api/task?id="+id, nil, http.MethodDelete)
+
+	// выполнение последнего повторения тоже удаляет исключения
+	ret, err = postJSON("api/task", map[string]any{"date": day(0), "title": "Последняя пробежка", "repeat": "d 1", "remaining": 1}, http.MethodPost)
+	assert.NoError(t, err)
+	id, _ = ret["id"].(string)
+	ret = addException(t, map[string]any{"task_id": id, "date": day(2), "kind": "skip"})
+	assert.Empty(t, ret["error"])
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.Equal(t, 0, exceptions(id))
+}
+
+// TestExceptionLimits проверяет исключения для частых правил и для задачи,
+// у которой после пропуска не остаётся повторений.
+func TestExceptionLimits(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	day := func(n int) string {
+		return now.AddDate(0, 0, n).Format(`20060102`)
+	}
+
+	// у h 1 повторение через 60 дней — тоже повторение, хотя до него больше
+	// тысячи повторений
+	hourly := addTask(t, task{date: day(0), title: "Проверить почту", repeat: "h 1"})
+	defer requestJSON("api/task?id="+hourly, nil, http.MethodDelete)
+	ret := addException(t, map[string]any{"task_id": hourly, "date": day(60), "kind": "skip"})
+	assert.Empty(t, ret["error"])
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rule, err := nextdate.Parse("min 1")
+	assert.NoError(t, err)
+	assert.True(t, nextdate.IsOccurrence(rule, start, "20240301"))
+	assert.False(t, nextdate.IsOccurrence(rule, start, "20231231"))
+
+	// пропущенный день правила min 1 пропускается целиком
+	skipping := nextdate.WithExceptions(rule, []string{"20240101", "20240102"})
+	assert.Equal(t, time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), skipping.Next(start, start))
+
+	// пропуск последнего повторения не оставляет задачу на пропущенной дате
+	for _, values := range []map[string]any{
+		{"date": day(0), "title": "Последний раз", "repeat": "d 1", "remaining": 1},
+		{"date": day(0), "title": "До сегодня", "repeat": "d 1", "until": day(0)},
+	} {
+		ret, err := postJSON("api/task", values, http.MethodPost)
+		assert.NoError(t, err)
+		id, _ := ret["id"].(string)
+
+		ret = addException(t, map[string]any{"task_id": id, "date": day(0), "kind": "skip"})
+		assert.NotEmpty(t, ret["error"])
+		assert.Empty(t, getExceptions(t, id).Exceptions)
+
+		var stored Task
+		assert.NoError(t, db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id))
+		assert.Equal(t, day(0), stored.Date)
+		requestJSON("api/task?id="+id, nil, http.MethodDelete)
+	}
+}
+
+func weekDay(date time.Time) string {
+	if date.Weekday() == time.Sunday {
+		return "7"
+	}
+	return fmt.Sprint(int(date.Weekday()))
+}
@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/PhilippElizarov/go_final_project/internal/nextdate"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFloatingRule(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	day := func(s string) time.Time {
+		d, err := time.Parse(`20060102`, s)
+		assert.NoError(t, err)
+		return d
+	}
+
+	for _, v := range []struct {
+		now, date, repeat, want string
+	}{
+		{"20240126", "20240101", "d+ 3", "20240129"},
+		{"20240126", "20240101", "w+ 2", "20240209"},
+		{"20240126", "20240101", "m+ 1", "20240226"},
+		// в феврале нет 31-го числа
+		{"20240131", "20240101", "m+ 1", "20240229"},
+		// выполнена заранее: отсчёт от дня выполнения
+		{"20240126", "20240201", "d+ 3", "20240129"},
+	} {
+		next, err := nextdate.NextDate(day(v.now), v.date, v.repeat)
+		assert.NoError(t, err, v.repeat)
+		assert.Equal(t, v.want, next, v.repeat)
+	}
+
+	// пока задача не выполнена, повторения отсчитываются от её даты
+	rule, err := nextdate.Parse("d+ 3")
+	if assert.NoError(t, err) {
+		assert.Equal(t, day("20240128"), rule.Next(day("20240126"), day("20240101")))
+		assert.Equal(t, "через 3 дня после выполнения", nextdate.Describe(rule, "ru"))
+	}
+	rule, err = nextdate.Parse("w+ 1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "a week after completion", nextdate.Describe(rule, "en"))
+	}
+
+	for _, repeat := range []string{"d+", "d+ 0", "w+ 53", "m+ 13", "d+ 3 +b"} {
+		_, err := nextdate.Parse(repeat)
+		assert.Error(t, err, repeat)
+	}
+
+	now := time.Now()
+	ret, err := postJSON("api/task", map[string]any{
+		"date": now.AddDate(0, 0, 1).Format(`20060102`), "title": "Полить кактус", "repeat": "d+ 3",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["error"])
+	id, _ := ret["id"].(string)
+	defer requestJSON("api/task?id="+id, nil, http.MethodDelete)
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	var stored Task
+	assert.NoError(t, db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id))
+	assert.Equal(t, now.AddDate(0, 0, 3).Format(`20060102`), stored.Date)
+	assert.Equal(t, "d+ 3", stored.Repeat)
+}
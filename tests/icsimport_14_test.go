@@ -0,0 +1,280 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func postRaw(apipath string, contentType string, body string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodPost, getURL(apipath), strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if len(FakeNow) > 0 {
+		req.Header.Set("X-Fake-Now", FakeNow)
+	}
+
+	client := &http.Client{}
+	if len(Token) > 0 {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return nil, err
+		}
+		jar.SetCookies(req.URL, []*http.Cookie{{Name: "token", Value: Token}})
+		client.Jar = jar
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+type importReport struct {
+	Created     int                 `json:"created"`
+	Skipped     int                 `json:"skipped"`
+	Unsupported int                 `json:"unsupported"`
+	Items       []map[string]string `json:"items"`
+	Error       string              `json:"error"`
+}
+
+func TestImportCalendar(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	future := time.Now().AddDate(0, 0, 10).Format(`20060102`)
+	past := time.Now().AddDate(0, 0, -10).Format(`20060102`)
+
+	cal := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"UID:standup@example.com",
+		"DTSTART;VALUE=DATE:" + future,
+		"SUMMARY:Стендап\\, команда",
+		"RRULE:FREQ=WEEKLY;BYDAY=MO,FR",
+		"BEGIN:VALARM",
+		"DESCRIPTION:Напоминание",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:first-monday@example.com",
+		"DTSTART:" + future + "T090000",
+		"SUMMARY:Первый понедельник",
+		"RRULE:FREQ=MONTHLY;BYDAY=1MO",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:setpos@example.com",
+		"DTSTART;VALUE=DATE:" + future,
+		"SUMMARY:Последний рабочий день",
+		"RRULE:FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:secondly@example.com",
+		"DTSTART:" + future + "T090000",
+		"SUMMARY:Каждую секунду",
+		"RRULE:FREQ=SECONDLY",
+		"END:VEVENT",
+		"BEGIN:VTODO",
+		"UID:todo@example.com",
+		"DUE;VALUE=DATE:" + future,
+		"SUMMARY:Очень длинный заголовок задачи, который календарь свернул",
+		"  на две строки",
+		"DESCRIPTION:Первая строка\\nвторая",
+		"END:VTODO",
+		"BEGIN:VEVENT",
+		"UID:nosummary@example.com",
+		"DTSTART;VALUE=DATE:" + future,
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:past@example.com",
+		"DTSTART;VALUE=DATE:" + past,
+		"SUMMARY:Прошедшая встреча",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+
+	body, err := postRaw("api/import/ics", "text/calendar", cal)
+	assert.NoError(t, err)
+
+	var report importReport
+	assert.NoError(t, json.Unmarshal(body, &report))
+	assert.Empty(t, report.Error)
+	assert.Equal(t, 4, report.Created)
+	assert.Equal(t, 2, report.Skipped)
+	assert.Equal(t, 1, report.Unsupported)
+
+	status := make(map[string]map[string]string)
+	for _, item := range report.Items {
+		status[item["uid"]] = item
+		if item["id"] != "" {
+			defer requestJSON("api/task?id="+item["id"], nil, http.MethodDelete)
+		}
+	}
+
+	assert.Equal(t, "created", status["standup@example.com"]["status"])
+	assert.Equal(t, "created", status["first-monday@example.com"]["status"])
+	assert.Equal(t, "created", status["setpos@example.com"]["status"])
+	assert.Equal(t, "unsupported", status["secondly@example.com"]["status"])
+	assert.NotEmpty(t, status["secondly@example.com"]["reason"])
+	assert.Equal(t, "created", status["todo@example.com"]["status"])
+	assert.Equal(t, "skipped", status["nosummary@example.com"]["status"])
+	assert.Equal(t, "skipped", status["past@example.com"]["status"])
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, status["standup@example.com"]["id"])
+	assert.NoError(t, err)
+	assert.Equal(t, future, task.Date)
+	assert.Equal(t, "Стендап, команда", task.Title)
+	assert.Equal(t, "", task.Comment)
+	assert.Equal(t, "w 1,5", task.Repeat)
+
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, status["first-monday@example.com"]["id"])
+	assert.NoError(t, err)
+	assert.Equal(t, "m 1:1", task.Repeat)
+	assert.Equal(t, "09:00", task.Time)
+
+	// правило без короткой записи сохраняется как RRULE
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, status["setpos@example.com"]["id"])
+	assert.NoError(t, err)
+	assert.Equal(t, "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", task.Repeat)
+
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, status["todo@example.com"]["id"])
+	assert.NoError(t, err)
+	assert.Equal(t, "Очень длинный заголовок задачи, который календарь свернул на две строки", task.Title)
+	assert.Equal(t, "Первая строка\nвторая", task.Comment)
+	assert.Equal(t, "", task.Repeat)
+
+	body, err = postRaw("api/import/ics", "text/calendar", "ooops")
+	assert.NoError(t, err)
+	report = importReport{}
+	assert.NoError(t, json.Unmarshal(body, &report))
+	assert.NotEmpty(t, report.Error)
+}
+
+// TestCalendarEndRoundTrip проверяет, что окончание повторений переживает
+// выгрузку в iCalendar и обратный импорт: COUNT становится remaining,
+// а UNTIL — until.
+func TestCalendarEndRoundTrip(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	day := func(n int) string {
+		return now.AddDate(0, 0, n).Format(`20060102`)
+	}
+
+	originals := []map[string]any{
+		{"date": day(3), "title": "Три раза через день", "repeat": "d 2", "remaining": 3},
+		{"date": day(3), "title": "До конца месяца", "repeat": "w 1,3", "until": day(33)},
+		{"date": day(3), "title": "Утренняя зарядка", "time": "09:00", "repeat": "d 1", "until": day(13)},
+	}
+	var ids []string
+	for _, values := range originals {
+		ret, err := postJSON("api/task", values, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Empty(t, ret["error"])
+		id, _ := ret["id"].(string)
+		ids = append(ids, id)
+		defer requestJSON("api/task?id="+id, nil, http.MethodDelete)
+	}
+
+	body, err := requestJSON("api/calendar.ics", nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	// в календарь для импорта попадают только события этих задач
+	cal := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"
+	for _, block := range strings.Split(string(body), "BEGIN:VEVENT\r\n")[1:] {
+		block, _, _ = strings.Cut(block, "END:VEVENT\r\n")
+		for _, id := range ids {
+			if strings.Contains(block, "UID:task-"+id+"@") {
+				cal += "BEGIN:VEVENT\r\n" + strings.Replace(block, "UID:task-", "UID:copy-", 1) + "END:VEVENT\r\n"
+			}
+		}
+	}
+	cal += "END:VCALENDAR\r\n"
+	assert.Contains(t, cal, "COUNT=3")
+
+	body, err = postRaw("api/import/ics", "text/calendar", cal)
+	assert.NoError(t, err)
+	var report importReport
+	assert.NoError(t, json.Unmarshal(body, &report))
+	assert.Empty(t, report.Error)
+	assert.Equal(t, len(originals), report.Created)
+
+	for _, item := range report.Items {
+		if item["id"] == "" {
+			continue
+		}
+		defer requestJSON("api/task?id="+item["id"], nil, http.MethodDelete)
+
+		// UID копии построен по id исходной задачи
+		id, _, _ := strings.Cut(strings.TrimPrefix(item["uid"], "copy-"), "@")
+		var original, copied Task
+		assert.NoError(t, db.Get(&original, `SELECT * FROM scheduler WHERE id=?`, id))
+		assert.NoError(t, db.Get(&copied, `SELECT * FROM scheduler WHERE id=?`, item["id"]))
+		assert.Equal(t, original.Date, copied.Date, original.Title)
+		assert.Equal(t, original.Time, copied.Time, original.Title)
+		assert.Equal(t, original.Repeat, copied.Repeat, original.Title)
+		assert.Equal(t, original.Until, copied.Until, original.Title)
+		assert.Equal(t, original.Remaining, copied.Remaining, original.Title)
+	}
+
+	// прошедшие повторения вычитаются из COUNT, а закончившиеся не импортируются
+	cal = strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"UID:count-past@example.com",
+		"DTSTART;VALUE=DATE:" + day(-5),
+		"SUMMARY:Десять дней подряд",
+		"RRULE:FREQ=DAILY;COUNT=10",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:count-over@example.com",
+		"DTSTART;VALUE=DATE:" + day(-5),
+		"SUMMARY:Три дня подряд",
+		"RRULE:FREQ=DAILY;COUNT=3",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:until-over@example.com",
+		"DTSTART;VALUE=DATE:" + day(-5),
+		"SUMMARY:До позавчера",
+		"RRULE:FREQ=DAILY;UNTIL=" + day(-2),
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+	body, err = postRaw("api/import/ics", "text/calendar", cal)
+	assert.NoError(t, err)
+	report = importReport{}
+	assert.NoError(t, json.Unmarshal(body, &report))
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 2, report.Skipped)
+	for _, item := range report.Items {
+		if item["id"] == "" {
+			continue
+		}
+		defer requestJSON("api/task?id="+item["id"], nil, http.MethodDelete)
+
+		var task Task
+		assert.NoError(t, db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, item["id"]))
+		date, err := time.Parse(`20060102`, task.Date)
+		assert.NoError(t, err)
+		start, err := time.Parse(`20060102`, day(-5))
+		assert.NoError(t, err)
+		// повторение номер k приходится на k-й день после начала
+		assert.Equal(t, 10-int(date.Sub(start).Hours()/24), task.Remaining)
+	}
+}
@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PhilippElizarov/go_final_project/internal/clock"
+	"github.com/PhilippElizarov/go_final_project/internal/natural"
+	"github.com/PhilippElizarov/go_final_project/internal/routes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNaturalDate(t *testing.T) {
+	// пятница
+	now := time.Date(2024, 1, 26, 15, 0, 0, 0, time.UTC)
+
+	tbl := []struct {
+		input string
+		want  string
+	}{
+		{"today", "20240126"},
+		{"Завтра", "20240127"},
+		{"послезавтра", "20240128"},
+		{"tomorrow", "20240127"},
+		{"in 3 days", "20240129"},
+		{"через 2 недели", "20240209"},
+		{"через месяц", "20240226"},
+		{"in a week", "20240202"},
+		{"next friday", "20240202"},
+		{"friday", "20240202"},
+		{"в понедельник", "20240129"},
+		{"в следующую среду", "20240131"},
+	}
+	for _, v := range tbl {
+		date, err := natural.ParseDate(v.input, now)
+		if assert.NoError(t, err, v.input) {
+			assert.Equal(t, v.want, date.Format(`20060102`), v.input)
+		}
+	}
+
+	for _, input := range []string{"", "ooops", "20240192", "28.01.2024", "через", "next month"} {
+		_, err := natural.ParseDate(input, now)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestNaturalRepeat(t *testing.T) {
+	// пятница
+	start := time.Date(2024, 1, 26, 0, 0, 0, 0, time.UTC)
+
+	tbl := []struct {
+		input  string
+		repeat string
+		date   string
+	}{
+		{"every day", "d 1", "20240126"},
+		{"ежедневно", "d 1", "20240126"},
+		{"каждые 3 дня", "d 3", "20240126"},
+		{"every week", "w 5", "20240126"},
+		{"every monday and wednesday", "w 1,3", "20240126"},
+		{"по понедельникам и средам", "w 1,3", "20240126"},
+		{"every 2 weeks on monday", "w 1 /2", "20240129"},
+		{"every 2 weeks on monday and friday", "w 1,5 /2", "20240126"},
+		{"каждые 3 недели по вторникам и четвергам", "w 2,4 /3", "20240130"},
+		{"every other week", "w 5 /2", "20240126"},
+		{"every month", "m 26", "20240126"},
+		{"ежегодно", "y", "20240126"},
+		{"каждые 2 часа", "h 2", "20240126"},
+		{"every 15 minutes", "min 15", "20240126"},
+		{"every weekday", "b 1", "20240126"},
+		{"по будням", "b 1", "20240126"},
+		{"каждые 3 рабочих дня", "b 3", "20240126"},
+	}
+	for _, v := range tbl {
+		repeat, date, err := natural.ParseRepeat(v.input, start)
+		if assert.NoError(t, err, v.input) {
+			assert.Equal(t, v.repeat, repeat, v.input)
+			assert.Equal(t, v.date, date.Format(`20060102`), v.input)
+		}
+	}
+
+	for _, input := range []string{"", "ooops", "w", "every", "every 2 months",
+		"every 60 weeks", "every 500 days", "каждый день по средам"} {
+		_, _, err := natural.ParseRepeat(input, start)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestDryRunTask(t *testing.T) {
+	store := newMemStore()
+	srv := httptest.NewServer(routes.NewServer(store, routes.Config{
+		Location: time.UTC,
+		Clock:    clock.Fixed(time.Date(2024, 1, 26, 15, 0, 0, 0, time.UTC)),
+	}))
+	defer srv.Close()
+
+	dryRun := func(values map[string]any) (int, map[string]string) {
+		data, err := json.Marshal(values)
+		assert.NoError(t, err)
+		resp, err := http.Post(srv.URL+"/api/task/dry-run", "application/json", bytes.NewReader(data))
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		var m map[string]string
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&m))
+		return resp.StatusCode, m
+	}
+
+	status, m := dryRun(map[string]any{"title": "Планёрка", "date": "tomorrow", "repeat": "every 2 weeks on monday"})
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "20240129", m["date"])
+	assert.Equal(t, "w 1 /2", m["repeat"])
+	assert.Equal(t, "по понедельникам каждые 2 недели", m["repeat_text"])
+
+	status, m = dryRun(map[string]any{"title": "Отчёт", "date": "20240101", "repeat": "каждый понедельник"})
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "20240129", m["date"])
+	assert.Equal(t, "w 1", m["repeat"])
+
+	status, m = dryRun(map[string]any{"title": "Задача", "date": "послезавтра"})
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "20240128", m["date"])
+
+	status, m = dryRun(map[string]any{"title": "Задача", "repeat": "ooops"})
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.NotEmpty(t, m["error"])
+
+	assert.Empty(t, store.tasks)
+}
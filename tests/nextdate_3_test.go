@@ -76,4 +76,40 @@ func TestNextDate(t *testing.T) {
 		{"20230226", "w 8,4,5", ""},
 	}
 	check()
+	tbl = []nextDate{
+		{"20240101", "w 1,4 /2", "20240129"},
+		{"20240108", "w 1,4 /2", "20240205"},
+		{"20240110", "w 7 /3", "20240204"},
+		{"20240126", "w 5 /1", "20240202"},
+		{"20240301", "w 5 /2", "20240315"},
+		{"20230102", "w 1 /52", "20241230"},
+		{"20240126", "w 1 /0", ""},
+		{"20240126", "w 1 /53", ""},
+		{"20240126", "w 1 2", ""},
+		{"20240126", "w /2", ""},
+		{"20240126", "w 1 /x", ""},
+		{"20240126", "w 1 /2 3", ""},
+		{"20240101", "m 1:1", "20240205"},
+		{"20240101", "m 2:2", "20240213"},
+		{"20240101", "m -1:5", "20240223"},
+		{"20240101", "m -1:3", "20240131"},
+		{"20240101", "m 5:4", "20240229"},
+		{"20240101", "m -1:7 3,6", "20240331"},
+		{"20240101", "m 1:1,15", "20240205"},
+		{"20240101", "m 3:3,-1", "20240131"},
+		{"20240101", "m 6:1", ""},
+		{"20240101", "m 0:1", ""},
+		{"20240101", "m -2:1", ""},
+		{"20240101", "m 1:8", ""},
+		{"20240101", "m 1:", ""},
+		{"20240126", "b 1", "20240129"},
+		{"20240119", "b 4", "20240131"},
+		{"20230223", "y +b", "20240226"},
+		{"20230308", "y -b", "20240307"},
+		{"20240126", "d 1 !b", "20240129"},
+		{"20240126", "b 0", ""},
+		{"20240126", "h 2 +b", ""},
+		{"20240126", "d 1 ?b", ""},
+	}
+	check()
 }
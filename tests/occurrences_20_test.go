@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type occurrence struct {
+	Date string `json:"date"`
+	Time string `json:"time"`
+}
+
+func getOccurrences(t *testing.T, values url.Values) ([]occurrence, map[string]any) {
+	body, err := requestJSON("api/occurrences?"+values.Encode(), nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	var list []occurrence
+	if err := json.Unmarshal(body, &list); err == nil {
+		return list, nil
+	}
+	var m map[string]any
+	assert.NoError(t, json.Unmarshal(body, &m))
+	return nil, m
+}
+
+func dates(list []occurrence) []string {
+	var res []string
+	for _, o := range list {
+		res = append(res, o.Date)
+	}
+	return res
+}
+
+func TestOccurrences(t *testing.T) {
+	list, _ := getOccurrences(t, url.Values{
+		"now": {"20240126"}, "date": {"20240113"}, "repeat": {"d 7"}, "count": {"3"},
+	})
+	assert.Equal(t, []string{"20240127", "20240203", "20240210"}, dates(list))
+
+	// будущая дата задачи — первое повторение
+	list, _ = getOccurrences(t, url.Values{
+		"now": {"20240126"}, "date": {"20240131"}, "repeat": {"m -1"}, "count": {"4"},
+	})
+	assert.Equal(t, []string{"20240131", "20240229", "20240331", "20240430"}, dates(list))
+
+	list, _ = getOccurrences(t, url.Values{
+		"now": {"20240126"}, "date": {"20240125"}, "repeat": {"w 1,5"}, "until": {"20240209"},
+	})
+	assert.Equal(t, []string{"20240129", "20240202", "20240205", "20240209"}, dates(list))
+
+	list, _ = getOccurrences(t, url.Values{
+		"now": {"20240126"}, "date": {"20240126"}, "time": {"22:00"}, "repeat": {"h 2"}, "count": {"3"},
+	})
+	assert.Equal(t, []occurrence{{"20240126", "22:00"}, {"20240127", "00:00"}, {"20240127", "02:00"}}, list)
+
+	list, _ = getOccurrences(t, url.Values{
+		"now": {"20240126"}, "date": {"20240126"}, "repeat": {"d 1"}, "count": {"100000"},
+	})
+	assert.Len(t, list, 100)
+
+	for _, values := range []url.Values{
+		{"date": {"20240126"}, "repeat": {"k 34"}},
+		{"date": {"20240126"}, "repeat": {""}},
+		{"date": {"ooops"}, "repeat": {"d 1"}},
+		{"date": {"20240126"}, "repeat": {"d 1"}, "count": {"0"}},
+		{"date": {"20240126"}, "repeat": {"d 1"}, "until": {"31.01.2024"}},
+	} {
+		list, m := getOccurrences(t, values)
+		assert.Nil(t, list, values)
+		assert.NotEmpty(t, m["error"], values)
+	}
+}
@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tasksPage struct {
+	Tasks      []map[string]string `json:"tasks"`
+	NextCursor string              `json:"next_cursor"`
+	Error      string              `json:"error"`
+}
+
+func getTasksPage(t *testing.T, values url.Values) tasksPage {
+	body, err := requestJSON("api/tasks?"+values.Encode(), nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	var page tasksPage
+	assert.NoError(t, json.Unmarshal(body, &page))
+	return page
+}
+
+func TestTasksPagination(t *testing.T) {
+	now := time.Now()
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		ids = append(ids, addTask(t, task{
+			date:  now.AddDate(0, 0, i%2).Format(`20060102`),
+			title: "Страница Пагинации",
+		}))
+	}
+	defer func() {
+		for _, id := range ids {
+			_, err := requestJSON("api/task?id="+id, nil, http.MethodDelete)
+			assert.NoError(t, err)
+		}
+	}()
+
+	var got []string
+	values := url.Values{"search": {"Пагинации"}, "limit": {"2"}}
+	for pages := 0; pages < 5; pages++ {
+		page := getTasksPage(t, values)
+		assert.Empty(t, page.Error)
+		assert.LessOrEqual(t, len(page.Tasks), 2)
+		for _, task := range page.Tasks {
+			got = append(got, task["id"])
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		values.Set("cursor", page.NextCursor)
+	}
+	assert.ElementsMatch(t, ids, got)
+
+	// задачи идут по дате, затем по id
+	assert.Equal(t, []string{ids[0], ids[2], ids[4], ids[1], ids[3]}, got)
+
+	page := getTasksPage(t, url.Values{"limit": {"0"}})
+	assert.NotEmpty(t, page.Error)
+	page = getTasksPage(t, url.Values{"cursor": {"ooops"}})
+	assert.NotEmpty(t, page.Error)
+}
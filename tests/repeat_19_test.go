@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/PhilippElizarov/go_final_project/internal/nextdate"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRepeat(t *testing.T) {
+	tbl := []struct {
+		repeat string
+		want   string
+	}{
+		{"d 7", "d 7"},
+		{"y", "y"},
+		{"w 1,3,5", "w 1,3,5"},
+		{"m -1,15", "m -1,15"},
+		{"m 07,19 05,6", "m 7,19 5,6"},
+		{"m -1:5,1,2:1 2,8", "m 1,-1:5,2:1 2,8"},
+		{"h 12", "h 12"},
+		{"min 30", "min 30"},
+	}
+	for _, v := range tbl {
+		rule, err := nextdate.Parse(v.repeat)
+		if !assert.NoError(t, err, v.repeat) {
+			continue
+		}
+		assert.Equal(t, v.want, rule.String())
+
+		again, err := nextdate.Parse(rule.String())
+		assert.NoError(t, err)
+		assert.Equal(t, rule, again)
+	}
+
+	rule, err := nextdate.Parse("m 1,-1 2,8")
+	assert.NoError(t, err)
+	assert.Equal(t, nextdate.MonthlyRule{Days: []int{1, -1}, Months: []int{2, 8}}, rule)
+}
+
+func TestValidateRepeat(t *testing.T) {
+	tbl := []struct {
+		repeat   string
+		position int
+		token    string
+	}{
+		{"", 0, ""},
+		{"k 34", 0, "k"},
+		{"d", 1, ""},
+		{"d 401", 2, "401"},
+		{"d 5 6", 4, "6"},
+		{"y 1", 2, "1"},
+		{"w 1,8", 4, "8"},
+		{"m 40,11,19", 2, "40"},
+		{"m -2,-3", 5, "-3"},
+		{"m 1 1,13", 6, "13"},
+		{"min 1441", 4, "1441"},
+		{"h x", 2, "x"},
+		{"m 1,6:1", 4, "6"},
+		{"m 1:9", 4, "9"},
+	}
+	for _, v := range tbl {
+		body, err := requestJSON("api/repeat/validate", map[string]any{"repeat": v.repeat}, http.MethodPost)
+		assert.NoError(t, err)
+		var m map[string]any
+		assert.NoError(t, json.Unmarshal(body, &m))
+
+		assert.Equal(t, false, m["valid"], v.repeat)
+		assert.NotEmpty(t, m["error"], v.repeat)
+		assert.Equal(t, float64(v.position), m["position"], v.repeat)
+		if v.token == "" {
+			assert.Nil(t, m["token"], v.repeat)
+		} else {
+			assert.Equal(t, v.token, m["token"], v.repeat)
+		}
+	}
+
+	body, err := requestJSON("api/repeat/validate", map[string]any{"repeat": "m 07,19 05,6"}, http.MethodPost)
+	assert.NoError(t, err)
+	var m map[string]any
+	assert.NoError(t, json.Unmarshal(body, &m))
+	assert.Equal(t, true, m["valid"])
+	assert.Equal(t, "m 7,19 5,6", m["rule"])
+	assert.Equal(t, "m", m["type"])
+}
@@ -0,0 +1,352 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PhilippElizarov/go_final_project/internal/nextdate"
+	"github.com/stretchr/testify/assert"
+)
+
+// примеры из раздела 3.8.5.3 RFC 5545; DTSTART в 09:00, если не указано иное
+var rfc5545Examples = []struct {
+	dtstart string
+	rrule   string
+	want    []string
+	total   int
+}{
+	{"19970902T0900", "FREQ=DAILY;COUNT=10", []string{
+		"19970902T0900", "19970903T0900", "19970904T0900", "19970905T0900", "19970906T0900",
+		"19970907T0900", "19970908T0900", "19970909T0900", "19970910T0900", "19970911T0900"}, 10},
+	{"19970902T0900", "FREQ=DAILY;UNTIL=19971224T000000Z", []string{
+		"19970902T0900", "19970903T0900", "19970904T0900"}, 113},
+	{"19970902T0900", "FREQ=DAILY;INTERVAL=2", []string{
+		"19970902T0900", "19970904T0900", "19970906T0900", "19970908T0900", "19970910T0900"}, 0},
+	{"19970902T0900", "FREQ=DAILY;INTERVAL=10;COUNT=5", []string{
+		"19970902T0900", "19970912T0900", "19970922T0900", "19971002T0900", "19971012T0900"}, 5},
+	{"19980101T0900", "FREQ=YEARLY;UNTIL=20000131T140000Z;BYMONTH=1;BYDAY=SU,MO,TU,WE,TH,FR,SA", []string{
+		"19980101T0900", "19980102T0900", "19980103T0900"}, 93},
+	{"19980101T0900", "FREQ=DAILY;UNTIL=20000131T140000Z;BYMONTH=1", []string{
+		"19980101T0900", "19980102T0900", "19980103T0900"}, 93},
+	{"19970902T0900", "FREQ=WEEKLY;COUNT=10", []string{
+		"19970902T0900", "19970909T0900", "19970916T0900", "19970923T0900", "19970930T0900",
+		"19971007T0900", "19971014T0900", "19971021T0900", "19971028T0900", "19971104T0900"}, 10},
+	{"19970902T0900", "FREQ=WEEKLY;INTERVAL=2;WKST=SU", []string{
+		"19970902T0900", "19970916T0900", "19970930T0900", "19971014T0900", "19971028T0900"}, 0},
+	{"19970902T0900", "FREQ=WEEKLY;COUNT=10;WKST=SU;BYDAY=TU,TH", []string{
+		"19970902T0900", "19970904T0900", "19970909T0900", "19970911T0900", "19970916T0900",
+		"19970918T0900", "19970923T0900", "19970925T0900", "19970930T0900", "19971002T0900"}, 10},
+	{"19970901T0900", "FREQ=WEEKLY;INTERVAL=2;UNTIL=19971224T000000Z;WKST=SU;BYDAY=MO,WE,FR", []string{
+		"19970901T0900", "19970903T0900", "19970905T0900", "19970915T0900", "19970917T0900",
+		"19970919T0900", "19970929T0900", "19971001T0900", "19971003T0900", "19971013T0900"}, 25},
+	{"19970902T0900", "FREQ=WEEKLY;INTERVAL=2;COUNT=8;WKST=SU;BYDAY=TU,TH", []string{
+		"19970902T0900", "19970904T0900", "19970916T0900", "19970918T0900",
+		"19970930T0900", "19971002T0900", "19971014T0900", "19971016T0900"}, 8},
+	{"19970905T0900", "FREQ=MONTHLY;COUNT=10;BYDAY=1FR", []string{
+		"19970905T0900", "19971003T0900", "19971107T0900", "19971205T0900", "19980102T0900",
+		"19980206T0900", "19980306T0900", "19980403T0900", "19980501T0900", "19980605T0900"}, 10},
+	{"19970907T0900", "FREQ=MONTHLY;INTERVAL=2;COUNT=10;BYDAY=1SU,-1SU", []string{
+		"19970907T0900", "19970928T0900", "19971102T0900", "19971130T0900", "19980104T0900",
+		"19980125T0900", "19980301T0900", "19980329T0900", "19980503T0900", "19980531T0900"}, 10},
+	{"19970922T0900", "FREQ=MONTHLY;COUNT=6;BYDAY=-2MO", []string{
+		"19970922T0900", "19971020T0900", "19971117T0900", "19971222T0900", "19980119T0900", "19980216T0900"}, 6},
+	{"19970928T0900", "FREQ=MONTHLY;BYMONTHDAY=-3", []string{
+		"19970928T0900", "19971029T0900", "19971128T0900", "19971229T0900", "19980129T0900", "19980226T0900"}, 0},
+	{"19970902T0900", "FREQ=MONTHLY;COUNT=10;BYMONTHDAY=2,15", []string{
+		"19970902T0900", "19970915T0900", "19971002T0900", "19971015T0900", "19971102T0900",
+		"19971115T0900", "19971202T0900", "19971215T0900", "19980102T0900", "19980115T0900"}, 10},
+	{"19970930T0900", "FREQ=MONTHLY;COUNT=10;BYMONTHDAY=1,-1", []string{
+		"19970930T0900", "19971001T0900", "19971031T0900", "19971101T0900", "19971130T0900",
+		"19971201T0900", "19971231T0900", "19980101T0900", "19980131T0900", "19980201T0900"}, 10},
+	{"19970910T0900", "FREQ=MONTHLY;INTERVAL=18;COUNT=10;BYMONTHDAY=10,11,12,13,14,15", []string{
+		"19970910T0900", "19970911T0900", "19970912T0900", "19970913T0900", "19970914T0900",
+		"19970915T0900", "19990310T0900", "19990311T0900", "19990312T0900", "19990313T0900"}, 10},
+	{"19970902T0900", "FREQ=MONTHLY;INTERVAL=2;BYDAY=TU", []string{
+		"19970902T0900", "19970909T0900", "19970916T0900", "19970923T0900", "19970930T0900",
+		"19971104T0900", "19971111T0900", "19971118T0900", "19971125T0900", "19980106T0900"}, 0},
+	{"19970610T0900", "FREQ=YEARLY;COUNT=10;BYMONTH=6,7", []string{
+		"19970610T0900", "19970710T0900", "19980610T0900", "19980710T0900", "19990610T0900",
+		"19990710T0900", "20000610T0900", "20000710T0900", "20010610T0900", "20010710T0900"}, 10},
+	{"19970310T0900", "FREQ=YEARLY;INTERVAL=2;COUNT=10;BYMONTH=1,2,3", []string{
+		"19970310T0900", "19990110T0900", "19990210T0900", "19990310T0900", "20010110T0900",
+		"20010210T0900", "20010310T0900", "20030110T0900", "20030210T0900", "20030310T0900"}, 10},
+	{"19970101T0900", "FREQ=YEARLY;INTERVAL=3;COUNT=10;BYYEARDAY=1,100,200", []string{
+		"19970101T0900", "19970410T0900", "19970719T0900", "20000101T0900", "20000409T0900",
+		"20000718T0900", "20030101T0900", "20030410T0900", "20030719T0900", "20060101T0900"}, 10},
+	{"19970519T0900", "FREQ=YEARLY;BYDAY=20MO", []string{
+		"19970519T0900", "19980518T0900", "19990517T0900"}, 0},
+	{"19970512T0900", "FREQ=YEARLY;BYWEEKNO=20;BYDAY=MO", []string{
+		"19970512T0900", "19980511T0900", "19990517T0900"}, 0},
+	{"19970313T0900", "FREQ=YEARLY;BYMONTH=3;BYDAY=TH", []string{
+		"19970313T0900", "19970320T0900", "19970327T0900", "19980305T0900",
+		"19980312T0900", "19980319T0900", "19980326T0900"}, 0},
+	{"19980213T0900", "FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13", []string{
+		"19980213T0900", "19980313T0900", "19981113T0900", "19990813T0900", "20001013T0900"}, 0},
+	{"19970913T0900", "FREQ=MONTHLY;BYDAY=SA;BYMONTHDAY=7,8,9,10,11,12,13", []string{
+		"19970913T0900", "19971011T0900", "19971108T0900", "19971213T0900", "19980110T0900",
+		"19980207T0900", "19980307T0900", "19980411T0900", "19980509T0900", "19980613T0900"}, 0},
+	{"19961105T0900", "FREQ=YEARLY;INTERVAL=4;BYMONTH=11;BYDAY=TU;BYMONTHDAY=2,3,4,5,6,7,8", []string{
+		"19961105T0900", "20001107T0900", "20041102T0900"}, 0},
+	{"19970904T0900", "FREQ=MONTHLY;COUNT=3;BYDAY=TU,WE,TH;BYSETPOS=3", []string{
+		"19970904T0900", "19971007T0900", "19971106T0900"}, 3},
+	{"19970929T0900", "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-2", []string{
+		"19970929T0900", "19971030T0900", "19971127T0900", "19971230T0900",
+		"19980129T0900", "19980226T0900", "19980330T0900"}, 0},
+	{"19970902T0900", "FREQ=HOURLY;INTERVAL=3;UNTIL=19970902T170000Z", []string{
+		"19970902T0900", "19970902T1200", "19970902T1500"}, 3},
+	{"19970902T0900", "FREQ=MINUTELY;INTERVAL=15;COUNT=6", []string{
+		"19970902T0900", "19970902T0915", "19970902T0930", "19970902T0945", "19970902T1000", "19970902T1015"}, 6},
+	{"19970902T0900", "FREQ=MINUTELY;INTERVAL=90;COUNT=4", []string{
+		"19970902T0900", "19970902T1030", "19970902T1200", "19970902T1330"}, 4},
+	{"19970902T0900", "FREQ=DAILY;BYHOUR=9,10,11,12,13,14,15,16;BYMINUTE=0,20,40", []string{
+		"19970902T0900", "19970902T0920", "19970902T0940", "19970902T1000"}, 0},
+	{"19970902T1620", "FREQ=MINUTELY;INTERVAL=20;BYHOUR=9,10,11,12,13,14,15,16", []string{
+		"19970902T1620", "19970902T1640", "19970903T0900", "19970903T0920"}, 0},
+	{"19970805T0900", "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=MO", []string{
+		"19970805T0900", "19970810T0900", "19970819T0900", "19970824T0900"}, 4},
+	{"19970805T0900", "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=SU", []string{
+		"19970805T0900", "19970817T0900", "19970819T0900", "19970831T0900"}, 4},
+	{"20070115T0900", "FREQ=MONTHLY;BYMONTHDAY=15,30;COUNT=5", []string{
+		"20070115T0900", "20070130T0900", "20070215T0900", "20070315T0900", "20070330T0900"}, 5},
+	{"19970902T0900", "RRULE:FREQ=YEARLY", []string{
+		"19970902T0900", "19980902T0900", "19990902T0900"}, 0},
+}
+
+func TestRFC5545(t *testing.T) {
+	const layout = "20060102T1504"
+
+	for _, v := range rfc5545Examples {
+		start, err := time.Parse(layout, v.dtstart)
+		assert.NoError(t, err)
+
+		rule, err := nextdate.Parse(v.rrule)
+		if !assert.NoError(t, err, v.rrule) {
+			continue
+		}
+
+		limit := len(v.want)
+		if v.total > 0 {
+			limit = v.total + 1
+		}
+		dates := nextdate.Upcoming(start, start, rule, limit, time.Time{})
+
+		var got []string
+		for _, d := range dates {
+			got = append(got, d.Format(layout))
+		}
+		if v.total > 0 {
+			assert.Len(t, got, v.total, v.rrule)
+		}
+		if len(got) > len(v.want) {
+			got = got[:len(v.want)]
+		}
+		assert.Equal(t, v.want, got, v.rrule)
+
+		// каноническая запись разбирается в то же правило
+		again, err := nextdate.Parse(rule.String())
+		if assert.NoError(t, err, rule.String()) {
+			assert.Equal(t, rule, again)
+		}
+	}
+
+	for _, v := range []struct {
+		rrule string
+		pos   int
+	}{
+		{"FREQ=SECONDLY", 5},
+		{"FREQ=FORTNIGHTLY", 5},
+		{"RRULE:INTERVAL=2", 16},
+		{"FREQ=DAILY;INTERVAL=0", 20},
+		{"FREQ=DAILY;COUNT=2;UNTIL=20240101", 25},
+		{"FREQ=DAILY;FREQ=WEEKLY", 11},
+		{"FREQ=DAILY;BYDAY=1MO", 17},
+		{"FREQ=MONTHLY;BYDAY=6MO", 19},
+		{"FREQ=MONTHLY;BYMONTHDAY=0", 24},
+		{"FREQ=WEEKLY;BYMONTHDAY=1", 24},
+		{"FREQ=MONTHLY;BYWEEKNO=1", 23},
+		{"FREQ=MONTHLY;BYSETPOS=-1", 24},
+		{"FREQ=DAILY;BYSECOND=30", 20},
+		{"FREQ=DAILY;UNTIL=tomorrow", 17},
+		{"RRULE:FREQ=DAILY;X-NAME=1", 17},
+		{"FREQ=DAILY;", 11},
+		{"FREQ=MINUTELY;COUNT=100000000", 20},
+	} {
+		_, err := nextdate.Parse(v.rrule)
+		var parseErr *nextdate.ParseError
+		if assert.True(t, errors.As(err, &parseErr), v.rrule) {
+			assert.Equal(t, v.pos, parseErr.Pos, "%s: %v", v.rrule, err)
+		}
+	}
+
+	// у завершённого правила следующего повторения нет: возвращается дата начала
+	rule, err := nextdate.Parse("FREQ=DAILY;COUNT=3")
+	assert.NoError(t, err)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, start, rule.Next(time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC), start))
+
+	advanced, changed := nextdate.Advance(rule, start, start.AddDate(0, 0, 1))
+	assert.True(t, changed)
+	assert.Equal(t, "FREQ=DAILY;COUNT=2", advanced.String())
+
+	assert.Equal(t, "каждый месяц (BYDAY=MO,TU;BYSETPOS=-1), всего 10 раз",
+		nextdate.Describe(mustParse(t, "FREQ=MONTHLY;BYDAY=MO,TU;BYSETPOS=-1;COUNT=10"), "ru"))
+	assert.Equal(t, "every 2 weeks, until 2024-12-31",
+		nextdate.Describe(mustParse(t, "FREQ=WEEKLY;INTERVAL=2;UNTIL=20241231"), "en"))
+
+	// частые сочетания частоты, интервала и BYxxx описываются словами
+	for _, v := range []struct{ rrule, ru, en string }{
+		{"FREQ=WEEKLY;BYDAY=WE,MO", "каждую неделю по понедельникам и средам", "every week on Monday and Wednesday"},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=TU;COUNT=4", "каждые 2 недели по вторникам, всего 4 раза", "every 2 weeks on Tuesday, 4 times"},
+		{"FREQ=MONTHLY;BYDAY=1MO,-1FR", "каждый месяц в первый понедельник и в последнюю пятницу", "every month on the first Monday and the last Friday"},
+		{"FREQ=MONTHLY;BYMONTHDAY=15,-1", "каждый месяц 15-го числа и в последний день", "every month on the 15th and the last day"},
+		{"FREQ=YEARLY;BYMONTH=11;BYDAY=4TH", "каждый год в четвёртый четверг в ноябре", "every year on the fourth Thursday in November"},
+		{"FREQ=DAILY;BYHOUR=9,18;BYMINUTE=0", "каждый день в 9:00 и 18:00", "every day at 9:00 and 18:00"},
+		{"FREQ=YEARLY;BYYEARDAY=100", "каждый год (BYYEARDAY=100)", "every year (BYYEARDAY=100)"},
+	} {
+		rule := mustParse(t, v.rrule)
+		assert.Equal(t, v.ru, nextdate.Describe(rule, "ru"), v.rrule)
+		assert.Equal(t, v.en, nextdate.Describe(rule, "en"), v.rrule)
+	}
+}
+
+// TestRRuleCountRuntime проверяет, что правила с COUNT от давней даты
+// не перебираются по одному периоду.
+func TestRRuleCountRuntime(t *testing.T) {
+	begin := time.Now()
+	at := func(s string) time.Time {
+		d, err := time.Parse("20060102 15:04", s)
+		assert.NoError(t, err)
+		return d
+	}
+
+	for _, repeat := range []string{
+		"FREQ=MINUTELY;COUNT=1000",
+		"FREQ=MINUTELY;BYHOUR=0;BYMINUTE=0;COUNT=1000",
+		"FREQ=HOURLY;BYDAY=MO;COUNT=1000",
+	} {
+		_, err := nextdate.Next(at("20260101 00:00"), at("20000101 00:00"), repeat)
+		assert.ErrorIs(t, err, nextdate.ErrNoOccurrences, repeat)
+	}
+
+	// пропущенные периоды считаются умножением, результат совпадает с перебором
+	for _, v := range []struct {
+		repeat   string
+		start    string
+		to       string
+		advanced string
+	}{
+		{"FREQ=HOURLY;COUNT=1000", "20240101 00:00", "20240105 13:00", "FREQ=HOURLY;COUNT=891"},
+		{"FREQ=DAILY;BYHOUR=9,18;COUNT=1000", "20240101 09:00", "20240301 09:00", "FREQ=DAILY;COUNT=880;BYHOUR=9,18"},
+		{"FREQ=WEEKLY;BYDAY=MO,WE,FR;COUNT=100", "20240101 00:00", "20240202 00:00", "FREQ=WEEKLY;COUNT=86;BYDAY=MO,WE,FR"},
+	} {
+		rule := mustParse(t, v.repeat)
+		next := rule.Next(at(v.to).Add(-time.Minute), at(v.start))
+		assert.Equal(t, at(v.to), next, v.repeat)
+		advanced, _ := nextdate.Advance(rule, at(v.start), at(v.to))
+		assert.Equal(t, v.advanced, advanced.String())
+	}
+
+	body, err := getBody("api/nextdate?now=20260101&date=20000101&repeat=" + url.QueryEscape("FREQ=MINUTELY;COUNT=100000000"))
+	assert.NoError(t, err)
+	assert.NotRegexp(t, `^\d{8}$`, strings.TrimSpace(string(body)))
+
+	assert.Less(t, time.Since(begin), 2*time.Second)
+}
+
+// правила, у которых INTERVAL никогда не попадает в BYMINUTE или BYHOUR,
+// не должны перебирать периоды до горизонта
+func TestRRuleUnreachableRuntime(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, v := range []struct {
+		repeat string
+		ok     bool
+	}{
+		{"FREQ=MINUTELY;INTERVAL=2;BYMINUTE=1", false},
+		{"FREQ=MINUTELY;INTERVAL=120;BYHOUR=1", false},
+		{"FREQ=MINUTELY;INTERVAL=7;BYHOUR=3;BYMINUTE=5", true},
+		{"FREQ=HOURLY;INTERVAL=2;BYHOUR=1", false},
+		{"FREQ=HOURLY;INTERVAL=5;BYHOUR=1", true},
+	} {
+		begin := time.Now()
+		_, err := nextdate.Next(start, start, v.repeat)
+		if v.ok {
+			assert.NoError(t, err, v.repeat)
+		} else {
+			assert.ErrorIs(t, err, nextdate.ErrNoOccurrences, v.repeat)
+		}
+		assert.Less(t, time.Since(begin), time.Second, v.repeat)
+	}
+
+	begin := time.Now()
+	for _, path := range []string{"api/nextdate?now=20240126&date=20240101&repeat=", "api/occurrences?date=20240101&repeat="} {
+		body, err := getBody(path + url.QueryEscape("FREQ=MINUTELY;INTERVAL=2;BYMINUTE=1"))
+		assert.NoError(t, err)
+		assert.NotRegexp(t, `\d{8}`, string(body))
+	}
+	assert.Less(t, time.Since(begin), 2*time.Second)
+}
+
+func mustParse(t *testing.T, repeat string) nextdate.Rule {
+	rule, err := nextdate.Parse(repeat)
+	assert.NoError(t, err)
+	return rule
+}
+
+func TestRRuleTask(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	for _, v := range []nextDate{
+		{"20240101", "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", "20240131"},
+		{"20240120", "FREQ=DAILY;COUNT=10", "20240127"},
+		{"20240101", "FREQ=DAILY;COUNT=3", ""},
+		{"20240101", "FREQ=WEEKLY;UNTIL=20240202;BYDAY=FR", "20240202"},
+		{"20240101", "FREQ=WEEKLY;UNTIL=20240201;BYDAY=FR", ""},
+		{"20240101", "FREQ=DAILY;BYDAY=1MO", ""},
+	} {
+		body, err := getBody(fmt.Sprintf("api/nextdate?now=20240126&date=%s&repeat=%s",
+			v.date, url.QueryEscape(v.repeat)))
+		assert.NoError(t, err)
+		next := strings.TrimSpace(string(body))
+		if v.want == "" {
+			// повторения закончились или правило некорректно
+			assert.NotRegexp(t, `^\d{8}$`, next, v.repeat)
+			continue
+		}
+		assert.Equal(t, v.want, next, v.repeat)
+	}
+
+	now := time.Now()
+	today := now.Format(`20060102`)
+
+	id := addTask(t, task{date: today, title: "Ретро", repeat: "FREQ=DAILY;COUNT=2"})
+	defer requestJSON("api/task?id="+id, nil, http.MethodDelete)
+
+	var stored Task
+	assert.NoError(t, db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id))
+	assert.Equal(t, "FREQ=DAILY;COUNT=2", stored.Repeat)
+
+	ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	assert.NoError(t, db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, id))
+	assert.Equal(t, now.AddDate(0, 0, 1).Format(`20060102`), stored.Date)
+	assert.Equal(t, "FREQ=DAILY;COUNT=1", stored.Repeat)
+
+	// последнее повторение выполнено: задача удаляется
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+	notFoundTask(t, id)
+
+	ret, err = postJSON("api/task", map[string]any{
+		"date": today, "title": "Ошибка", "repeat": "FREQ=HOURLY;BYDAY=1MO",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+}
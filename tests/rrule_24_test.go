@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/PhilippElizarov/go_final_project/internal/ical"
+	"github.com/PhilippElizarov/go_final_project/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonthlyWeekDayRRule(t *testing.T) {
+	event, err := ical.TaskEvent(model.Task{ID: "1", Date: "20240101", Title: "Планирование", Repeat: "m 1:1,-1:5 3,9"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "FREQ=MONTHLY;BYDAY=1MO,-1FR;BYMONTH=3,9", event.RRule)
+
+	// дни месяца и дни недели вместе нельзя выразить одним RRULE
+	event, err = ical.TaskEvent(model.Task{ID: "2", Date: "20240101", Title: "Отчёт", Repeat: "m 15,-1:5"}, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, event.RRule)
+	assert.NotEmpty(t, event.RDates)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	repeat, err := ical.FromRRule("FREQ=YEARLY;BYMONTH=11;BYDAY=4TH", start)
+	assert.NoError(t, err)
+	assert.Equal(t, "m 4:4 11", repeat.Rule)
+
+	_, err = ical.FromRRule("FREQ=MONTHLY;BYDAY=MO", start)
+	assert.ErrorIs(t, err, ical.ErrUnsupportedRRule)
+	_, err = ical.FromRRule("FREQ=YEARLY;BYDAY=20MO", start)
+	assert.ErrorIs(t, err, ical.ErrUnsupportedRRule)
+}
+
+func TestRRuleEnd(t *testing.T) {
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	repeat, err := ical.FromRRule("FREQ=DAILY;COUNT=5", start)
+	assert.NoError(t, err)
+	assert.Equal(t, ical.Repeat{Rule: "d 1", Remaining: 5}, repeat)
+
+	repeat, err = ical.FromRRule("FREQ=WEEKLY;BYDAY=MO;UNTIL=20240331", start)
+	assert.NoError(t, err)
+	assert.Equal(t, ical.Repeat{Rule: "w 1", Until: "20240331"}, repeat)
+
+	// UNTIL раньше времени начала исключает свой день
+	repeat, err = ical.FromRRule("FREQ=DAILY;UNTIL=20240131T080000Z", start)
+	assert.NoError(t, err)
+	assert.Equal(t, "20240130", repeat.Until)
+	repeat, err = ical.FromRRule("FREQ=DAILY;UNTIL=20240131T235959", start)
+	assert.NoError(t, err)
+	assert.Equal(t, "20240131", repeat.Until)
+
+	for _, rrule := range []string{
+		"FREQ=DAILY;COUNT=0",
+		"FREQ=DAILY;COUNT=3;UNTIL=20240131",
+		"FREQ=HOURLY;UNTIL=20240131T120000",
+		"FREQ=DAILY;UNTIL=31.01.2024",
+	} {
+		_, err = ical.FromRRule(rrule, start)
+		assert.ErrorIs(t, err, ical.ErrUnsupportedRRule, rrule)
+	}
+}
@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/PhilippElizarov/go_final_project/internal/model"
+	"github.com/PhilippElizarov/go_final_project/internal/routes"
+	"github.com/stretchr/testify/assert"
+)
+
+// memStore — хранилище задач в памяти для проверки обработчиков без запущенного сервера.
+type memStore struct {
+	tasks  map[string]model.Task
+	nextID int
+	doneAt time.Time
+	feed   string
+}
+
+func newMemStore() *memStore {
+	return &memStore{tasks: map[string]model.Task{}}
+}
+
+func (m *memStore) AddTask(task model.Task) (model.Response, error) {
+	m.nextID++
+	task.ID = strconv.Itoa(m.nextID)
+	m.tasks[task.ID] = task
+	return model.Response{Id: task.ID}, nil
+}
+
+func (m *memStore) GetTasks(filter model.TaskFilter) (model.Tasks, error) {
+	var tasks model.Tasks
+	for _, task := range m.tasks {
+		tasks.Tasks = append(tasks.Tasks, task)
+	}
+	return tasks, nil
+}
+
+func (m *memStore) GetTaskByID(id string) (model.Task, error) {
+	task, ok := m.tasks[id]
+	if !ok {
+		return task, sql.ErrNoRows
+	}
+	return task, nil
+}
+
+func (m *memStore) UpdateTask(task model.Task) error {
+	if _, ok := m.tasks[task.ID]; !ok {
+		return sql.ErrNoRows
+	}
+	m.tasks[task.ID] = task
+	return nil
+}
+
+func (m *memStore) DoneTask(id string, doneAt time.Time) error {
+	m.doneAt = doneAt
+	return m.DeleteTask(id)
+}
+
+func (m *memStore) DeleteTask(id string) error {
+	if _, ok := m.tasks[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(m.tasks, id)
+	return nil
+}
+
+func (m *memStore) ForEachTask(fn func(task model.Task) error) error {
+	for _, task := range m.tasks {
+		if err := fn(task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memStore) ReplaceFeedToken(hash string) error {
+	m.feed = hash
+	return nil
+}
+
+func (m *memStore) RevokeFeedTokens() error {
+	m.feed = ""
+	return nil
+}
+
+func (m *memStore) IsFeedToken(hash string) (bool, error) {
+	return m.feed != "" && m.feed == hash, nil
+}
+
+func TestServerWithMemStore(t *testing.T) {
+	store := newMemStore()
+	srv := httptest.NewServer(routes.NewServer(store, routes.Config{}))
+	defer srv.Close()
+
+	data, err := json.Marshal(map[string]any{"title": "Задача в памяти", "repeat": "d 2"})
+	assert.NoError(t, err)
+
+	resp, err := http.Post(srv.URL+"/api/task", "application/json", bytes.NewReader(data))
+	assert.NoError(t, err)
+	var m map[string]any
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&m))
+	resp.Body.Close()
+	assert.Equal(t, "1", m["id"])
+	assert.Equal(t, "Задача в памяти", store.tasks["1"].Title)
+
+	resp, err = http.Get(srv.URL + "/api/task?id=1")
+	assert.NoError(t, err)
+	m = nil
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&m))
+	resp.Body.Close()
+	assert.Equal(t, "d 2", m["repeat"])
+
+	resp, err = http.Get(srv.URL + "/api/task?id=2")
+	assert.NoError(t, err)
+	m = nil
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&m))
+	resp.Body.Close()
+	assert.NotEmpty(t, m["error"])
+}
@@ -5,3 +5,5 @@ var DBFile = "../internal/database/scheduler.db"
 var FullNextDate = true
 var Search = true
 var Token = ``
+var Password = ``
+var FakeNow = ``
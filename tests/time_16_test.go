@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextDateHoursMinutes(t *testing.T) {
+	tbl := []nextDate{
+		{"20240126", "h 5", "20240126"},
+		{"20240120", "h 24", "20240127"},
+		{"20240120", "min 720", "20240126"},
+		{"20240126", "h 0", ""},
+		{"20240126", "h 169", ""},
+		{"20240126", "min 1441", ""},
+		{"20240126", "min", ""},
+		{"20240126", "mi 5", ""},
+	}
+	for _, v := range tbl {
+		urlPath := fmt.Sprintf("api/nextdate?now=20240126&date=%s&repeat=%s",
+			url.QueryEscape(v.date), url.QueryEscape(v.repeat))
+		get, err := getBody(urlPath)
+		assert.NoError(t, err)
+		next := strings.TrimSpace(string(get))
+		_, err = time.Parse("20060102", next)
+		if err != nil && len(v.want) == 0 {
+			continue
+		}
+		assert.Equal(t, v.want, next, `{%q, %q, %q}`, v.date, v.repeat, v.want)
+	}
+}
+
+func TestTaskTime(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	today := now.Format(`20060102`)
+
+	m, err := postJSON("api/task", map[string]any{
+		"date":  today,
+		"time":  "25:00",
+		"title": "Неверное время",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, m["error"])
+
+	m, err = postJSON("api/task", map[string]any{
+		"date":   today,
+		"time":   "09:30",
+		"title":  "Зарядка",
+		"repeat": "d 1",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	daily := fmt.Sprint(m["id"])
+	defer requestJSON("api/task?id="+daily, nil, http.MethodDelete)
+
+	body, err := requestJSON("api/task?id="+daily, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), `"time":"09:30"`)
+
+	ret, err := postJSON("api/task/done?id="+daily, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	var stored Task
+	assert.NoError(t, db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, daily))
+	assert.Equal(t, now.AddDate(0, 0, 1).Format(`20060102`), stored.Date)
+	assert.Equal(t, "09:30", stored.Time)
+
+	m, err = postJSON("api/task", map[string]any{
+		"date":   today,
+		"time":   "08:00",
+		"title":  "Проветрить",
+		"repeat": "h 2",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	hourly := fmt.Sprint(m["id"])
+	defer requestJSON("api/task?id="+hourly, nil, http.MethodDelete)
+
+	doneAt := time.Now()
+	ret, err = postJSON("api/task/done?id="+hourly, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	next := time.Date(now.Year(), now.Month(), now.Day(), 8, 0, 0, 0, time.Local).Add(2 * time.Hour)
+	for !next.After(doneAt) {
+		next = next.Add(2 * time.Hour)
+	}
+	assert.NoError(t, db.Get(&stored, `SELECT * FROM scheduler WHERE id=?`, hourly))
+	assert.Equal(t, next.Format(`20060102`), stored.Date)
+	assert.Equal(t, next.Format(`15:04`), stored.Time)
+
+	// задачи без времени по-прежнему отдаются без поля time
+	id := addTask(t, task{date: today, title: "Без времени"})
+	defer requestJSON("api/task?id="+id, nil, http.MethodDelete)
+	body, err = requestJSON("api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(body), `"time"`)
+}
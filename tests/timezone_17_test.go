@@ -0,0 +1,46 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimezone(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	// между этими поясами 25 часов, поэтому «сегодня» в них всегда разное
+	for _, name := range []string{"Pacific/Kiritimati", "Pacific/Pago_Pago"} {
+		loc, err := time.LoadLocation(name)
+		assert.NoError(t, err)
+
+		ret, err := postJSON("api/task?tz="+name, map[string]any{
+			"title": "Задача в поясе " + name,
+		}, http.MethodPost)
+		assert.NoError(t, err)
+		id, _ := ret["id"].(string)
+		if !assert.NotEmpty(t, id, ret) {
+			continue
+		}
+
+		body, err := requestJSON("api/task?id="+id, nil, http.MethodGet)
+		assert.NoError(t, err)
+		var m map[string]string
+		assert.NoError(t, json.Unmarshal(body, &m))
+		assert.Equal(t, time.Now().In(loc).Format(`20060102`), m["date"], name)
+
+		_, err = requestJSON("api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+	}
+
+	ret, err := postJSON("api/task?tz=Mars/Olympus", map[string]any{
+		"title": "Задача",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+	assert.Empty(t, ret["id"])
+}
@@ -0,0 +1,28 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/PhilippElizarov/go_final_project/internal/ical"
+	"github.com/PhilippElizarov/go_final_project/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWeeklyIntervalRRule(t *testing.T) {
+	event, err := ical.TaskEvent(model.Task{ID: "1", Date: "20240101", Title: "Ревью спринта", Repeat: "w 1,4 /2"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "FREQ=WEEKLY;INTERVAL=2;WKST=MO;BYDAY=MO,TH", event.RRule)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	repeat, err := ical.FromRRule(event.RRule, start)
+	assert.NoError(t, err)
+	assert.Equal(t, "w 1,4 /2", repeat.Rule)
+
+	repeat, err = ical.FromRRule("FREQ=WEEKLY;INTERVAL=3", start)
+	assert.NoError(t, err)
+	assert.Equal(t, "w 1 /3", repeat.Rule)
+
+	_, err = ical.FromRRule("FREQ=WEEKLY;INTERVAL=2;WKST=SU;BYDAY=MO", start)
+	assert.ErrorIs(t, err, ical.ErrUnsupportedRRule)
+}